@@ -0,0 +1,334 @@
+package dingtalk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+const (
+	// DingTalk open platform API endpoints of enterprise internal apps, relative to the API base URL
+	defaultAPIBaseURL = "https://api.dingtalk.com"
+	tokenPath         = "/v1.0/oauth2/accessToken"
+	batchSendPath     = "/v1.0/robot/oToMessages/batchSend"
+	groupSendPath     = "/v1.0/robot/groupMessages/send"
+	batchRecallPath   = "/v1.0/robot/otoMessages/batchRecall"
+	groupRecallPath   = "/v1.0/robot/groupMessages/recall"
+)
+
+// refreshToken gets a new access token of the enterprise internal app
+// 参考: https://open.dingtalk.com/document/orgapp/obtain-the-access_token-of-an-internal-app
+func (c *Client) refreshToken(ctx context.Context) error {
+	body, err := json.Marshal(map[string]string{
+		"appKey":    c.config.AppKey,
+		"appSecret": c.config.AppSecret,
+	})
+	if err != nil {
+		return err
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"accessToken"`
+		ExpireIn    int    `json:"expireIn"`
+	}
+	if _, err := c.callAPI(ctx, tokenPath, "", body, &tokenResp); err != nil {
+		return err
+	}
+
+	c.tokenMu.Lock()
+	c.token = tokenResp.AccessToken
+	c.tokenExpiry = time.Now().Add(time.Duration(tokenResp.ExpireIn-300) * time.Second) // Refresh 5 min early
+	c.tokenMu.Unlock()
+
+	return nil
+}
+
+// getToken returns a valid access token, refreshing if necessary
+func (c *Client) getToken(ctx context.Context) (string, error) {
+	c.tokenMu.RLock()
+	if time.Now().Before(c.tokenExpiry) {
+		token := c.token
+		c.tokenMu.RUnlock()
+		return token, nil
+	}
+	c.tokenMu.RUnlock()
+
+	if err := c.refreshToken(ctx); err != nil {
+		return "", err
+	}
+
+	c.tokenMu.RLock()
+	token := c.token
+	c.tokenMu.RUnlock()
+	return token, nil
+}
+
+// sendViaApp sends a message template (see appMessage) to a single user
+// (robot/oToMessages/batchSend) or group (robot/groupMessages/send) as the app's robot
+// 参考: https://open.dingtalk.com/document/orgapp/chatbots-send-one-on-one-chat-messages-in-batches
+func (c *Client) sendViaApp(ctx context.Context, msgKey, msgParam string, target types.Target) (*types.SendResult, error) {
+	// Wait for the client-wide and per-target quotas before every attempt
+	if err := c.limiter.Wait(ctx, target.Key()); err != nil {
+		return nil, err
+	}
+
+	token, err := c.getToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	reqBody := map[string]interface{}{
+		"robotCode": c.robotCode(),
+		"msgKey":    msgKey,
+		"msgParam":  msgParam,
+	}
+	path := groupSendPath
+	if target.ChatType == types.ChatTypePrivate {
+		path = batchSendPath
+		reqBody["userIds"] = []string{target.ID}
+	} else {
+		reqBody["openConversationId"] = target.ID
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var apiResp struct {
+		ProcessQueryKey           string   `json:"processQueryKey"`
+		InvalidStaffIDList        []string `json:"invalidStaffIdList"`
+		FlowControlledStaffIDList []string `json:"flowControlledStaffIdList"`
+	}
+	respBody, err := c.callAPI(ctx, path, token, body, &apiResp)
+	if err != nil {
+		return nil, err
+	}
+
+	// batchSend accepts the request but reports the users it couldn't deliver to
+	if len(apiResp.InvalidStaffIDList) > 0 {
+		return nil, &types.APIError{
+			Platform: "dingtalk",
+			Message:  fmt.Sprintf("user %s is invalid or outside the app's visible range", target.ID),
+			Kind:     types.ErrorKindInvalidTarget,
+		}
+	}
+	if len(apiResp.FlowControlledStaffIDList) > 0 {
+		return nil, &types.APIError{
+			Platform: "dingtalk",
+			Message:  fmt.Sprintf("messages to user %s are flow controlled", target.ID),
+			Kind:     types.ErrorKindRateLimited,
+		}
+	}
+
+	// processQueryKey identifies the message for read receipts and recalls
+	return &types.SendResult{
+		Target:    target,
+		MessageID: apiResp.ProcessQueryKey,
+		ChatID:    target.ID,
+		Timestamp: time.Now(),
+		Raw:       respBody,
+	}, nil
+}
+
+// recallViaApp withdraws a message sent by sendViaApp, identified by its
+// processQueryKey, from a single chat (robot/otoMessages/batchRecall) or group
+// (robot/groupMessages/recall)
+func (c *Client) recallViaApp(ctx context.Context, receipt *types.SendResult) error {
+	token, err := c.getToken(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	reqBody := map[string]interface{}{
+		"robotCode":        c.robotCode(),
+		"processQueryKeys": []string{receipt.MessageID},
+	}
+	path := groupRecallPath
+	if receipt.Target.ChatType == types.ChatTypePrivate {
+		path = batchRecallPath
+	} else {
+		reqBody["openConversationId"] = receipt.ChatID
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return err
+	}
+
+	var apiResp struct {
+		FailedResult map[string]string `json:"failedResult"` // processQueryKey -> reason
+	}
+	if _, err := c.callAPI(ctx, path, token, body, &apiResp); err != nil {
+		return err
+	}
+	if reason, failed := apiResp.FailedResult[receipt.MessageID]; failed {
+		return &types.APIError{
+			Platform: "dingtalk",
+			Message:  fmt.Sprintf("failed to recall message %s: %s", receipt.MessageID, reason),
+			Kind:     types.ErrorKindInvalidRequest,
+		}
+	}
+	return nil
+}
+
+// robotCode returns the robot code of the app, which is the AppKey unless configured
+func (c *Client) robotCode() string {
+	if c.config.RobotCode != "" {
+		return c.config.RobotCode
+	}
+	return c.config.AppKey
+}
+
+// callAPI posts a JSON body to an open platform API, decodes the response into out
+// and returns the raw response. Errors are reported with a non-2xx status and a
+// {"code", "message", "requestid"} body.
+func (c *Client) callAPI(ctx context.Context, path, token string, body []byte, out interface{}) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.apiBaseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("x-acs-dingtalk-access-token", token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var errResp struct {
+			Code      string `json:"code"`
+			Message   string `json:"message"`
+			RequestID string `json:"requestid"`
+		}
+		_ = json.Unmarshal(respBody, &errResp)
+		return nil, c.appAPIError(resp, errResp.Code, errResp.Message, errResp.RequestID)
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return nil, err
+	}
+	return respBody, nil
+}
+
+// appMessage maps a message to a robot message template (msgKey) and its
+// JSON-encoded parameters (msgParam). Cards are converted from the webhook
+// payloads built by Link and NewActionCard; feed cards have no template.
+// 参考: https://open.dingtalk.com/document/orgapp/types-of-messages-sent-by-robots
+func appMessage(msg *types.Message) (string, string, error) {
+	var msgKey string
+	var param map[string]interface{}
+
+	switch msg.Type {
+	case types.MessageTypeMarkdown:
+		msgKey = "sampleMarkdown"
+		param = map[string]interface{}{"title": "Message", "text": msg.Content}
+	case types.MessageTypeImage:
+		msgKey = "sampleImageMsg"
+		param = map[string]interface{}{"photoURL": msg.Content}
+	case types.MessageTypeCard:
+		var err error
+		msgKey, param, err = cardTemplate(msg.Content)
+		if err != nil {
+			return "", "", err
+		}
+	default:
+		msgKey = "sampleText"
+		param = map[string]interface{}{"content": msg.Content}
+	}
+
+	msgParam, err := json.Marshal(param)
+	if err != nil {
+		return "", "", err
+	}
+	return msgKey, string(msgParam), nil
+}
+
+// cardTemplate converts a link or actionCard webhook payload to a robot message template
+func cardTemplate(content string) (string, map[string]interface{}, error) {
+	var payload struct {
+		MsgType string `json:"msgtype"`
+		Link    struct {
+			Title      string `json:"title"`
+			Text       string `json:"text"`
+			MessageURL string `json:"messageUrl"`
+			PicURL     string `json:"picUrl"`
+		} `json:"link"`
+		ActionCard struct {
+			Title          string `json:"title"`
+			Text           string `json:"text"`
+			SingleTitle    string `json:"singleTitle"`
+			SingleURL      string `json:"singleURL"`
+			BtnOrientation string `json:"btnOrientation"`
+			Btns           []struct {
+				Title     string `json:"title"`
+				ActionURL string `json:"actionURL"`
+			} `json:"btns"`
+		} `json:"actionCard"`
+	}
+	if err := json.Unmarshal([]byte(content), &payload); err != nil {
+		return "", nil, types.Permanent(fmt.Errorf("invalid card JSON: %w", err))
+	}
+
+	switch payload.MsgType {
+	case "link":
+		return "sampleLink", map[string]interface{}{
+			"title":      payload.Link.Title,
+			"text":       payload.Link.Text,
+			"messageUrl": payload.Link.MessageURL,
+			"picUrl":     payload.Link.PicURL,
+		}, nil
+
+	case "actionCard":
+		card := payload.ActionCard
+		param := map[string]interface{}{
+			"title": card.Title,
+			"text":  card.Text,
+		}
+		if card.SingleURL != "" {
+			param["singleTitle"] = card.SingleTitle
+			param["singleURL"] = card.SingleURL
+			return "sampleActionCard", param, nil
+		}
+
+		n := len(card.Btns)
+		if n < 2 || n > 5 {
+			return "", nil, types.Permanent(fmt.Errorf("app robots support action cards with 2-5 buttons, got %d", n))
+		}
+
+		// sampleActionCard6 puts 2 buttons side by side ("1" is horizontal) and
+		// names their parameters differently
+		if n == 2 && card.BtnOrientation == "1" {
+			for i, btn := range card.Btns {
+				param[fmt.Sprintf("buttonTitle%d", i+1)] = btn.Title
+				param[fmt.Sprintf("buttonUrl%d", i+1)] = btn.ActionURL
+			}
+			return "sampleActionCard6", param, nil
+		}
+
+		// sampleActionCard2-5 stack 2-5 buttons vertically
+		for i, btn := range card.Btns {
+			param[fmt.Sprintf("actionTitle%d", i+1)] = btn.Title
+			param[fmt.Sprintf("actionURL%d", i+1)] = btn.ActionURL
+		}
+		return fmt.Sprintf("sampleActionCard%d", n), param, nil
+
+	default:
+		return "", nil, types.Permanent(fmt.Errorf("app robots don't support %q cards", payload.MsgType))
+	}
+}
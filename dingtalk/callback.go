@@ -0,0 +1,256 @@
+package dingtalk
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+const (
+	// maxCallbackBody limits the size of an outgoing robot callback body
+	maxCallbackBody = 1 << 20
+
+	// maxCallbackSkew is how far the timestamp header of a callback may be from
+	// the local clock, as required by DingTalk
+	maxCallbackSkew = time.Hour
+)
+
+// OutgoingMessage is the body DingTalk posts to an outgoing robot's callback URL
+// when the robot is @-mentioned in a group or messaged one-to-one.
+// 参考: https://open.dingtalk.com/document/orgapp/receive-message
+type OutgoingMessage struct {
+	MsgType string `json:"msgtype"`
+	Text    struct {
+		Content string `json:"content"`
+	} `json:"text"`
+	MsgID                     string   `json:"msgId"`
+	CreateAt                  int64    `json:"createAt"`         // Milliseconds since epoch
+	ConversationType          string   `json:"conversationType"` // "1" for one-to-one, "2" for group
+	ConversationID            string   `json:"conversationId"`
+	ConversationTitle         string   `json:"conversationTitle"` // Group name, empty for one-to-one
+	SenderID                  string   `json:"senderId"`          // Encrypted sender ID
+	SenderNick                string   `json:"senderNick"`
+	SenderStaffID             string   `json:"senderStaffId"`             // Sender's userid, only for members of the robot's organization
+	SenderCorpID              string   `json:"senderCorpId"`              // Sender's organization
+	IsAdmin                   bool     `json:"isAdmin"`                   // Whether the sender is an administrator
+	ChatbotUserID             string   `json:"chatbotUserId"`             // Encrypted ID of the robot
+	RobotCode                 string   `json:"robotCode"`                 // Robot code, for the app mode APIs
+	IsInAtList                bool     `json:"isInAtList"`                // Whether the robot was @-mentioned
+	AtUsers                   []AtUser `json:"atUsers"`                   // Users mentioned in the message, including the robot
+	SessionWebhook            string   `json:"sessionWebhook"`            // URL replies to this conversation are posted to, see Reply
+	SessionWebhookExpiredTime int64    `json:"sessionWebhookExpiredTime"` // Milliseconds since epoch
+}
+
+// AtUser is a user mentioned in an outgoing robot message
+type AtUser struct {
+	DingtalkID string `json:"dingtalkId"` // Encrypted ID
+	StaffID    string `json:"staffId"`    // userid, only for members of the robot's organization
+}
+
+// IsGroup reports whether the message was sent in a group
+func (m *OutgoingMessage) IsGroup() bool {
+	return m.ConversationType == "2"
+}
+
+// Reply posts msg to the conversation through the session webhook with
+// httpClient (http.DefaultClient if nil). Text, markdown and card messages are
+// supported, as for the webhook robot; SendOptions.AtUsers and
+// Extra[MentionsKey] mention users.
+func (m *OutgoingMessage) Reply(ctx context.Context, httpClient *http.Client, msg *types.Message, opts *types.SendOptions) error {
+	if m.SessionWebhook == "" {
+		return fmt.Errorf("message has no session webhook")
+	}
+	if m.SessionWebhookExpiredTime > 0 && time.Now().UnixMilli() > m.SessionWebhookExpiredTime {
+		return fmt.Errorf("session webhook expired at %s", time.UnixMilli(m.SessionWebhookExpiredTime).Format(time.RFC3339))
+	}
+	if opts == nil {
+		opts = &types.SendOptions{}
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	reqBody, err := robotBody(msg, opts)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", m.SessionWebhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = readRobotResponse(resp)
+	return err
+}
+
+// toIncoming normalizes the message into a types.IncomingMessage
+func (m *OutgoingMessage) toIncoming(raw []byte) *types.IncomingMessage {
+	chatType := types.ChatTypeGroup
+	if m.ConversationType == "1" {
+		chatType = types.ChatTypePrivate
+	}
+
+	senderID := m.SenderStaffID
+	if senderID == "" {
+		senderID = m.SenderID
+	}
+
+	// The robot itself is always in atUsers when it's mentioned in a group
+	mentions := make([]types.Mention, 0, len(m.AtUsers))
+	for _, u := range m.AtUsers {
+		if u.DingtalkID == m.ChatbotUserID {
+			continue
+		}
+		id := u.StaffID
+		if id == "" {
+			id = u.DingtalkID
+		}
+		mentions = append(mentions, types.Mention{ID: id})
+	}
+
+	timestamp := time.Now()
+	if m.CreateAt > 0 {
+		timestamp = time.UnixMilli(m.CreateAt)
+	}
+
+	return &types.IncomingMessage{
+		Platform:  "dingtalk",
+		ChatType:  chatType,
+		ChatID:    m.ConversationID,
+		MessageID: m.MsgID,
+		Sender: types.Sender{
+			ID:   senderID,
+			Name: m.SenderNick,
+		},
+		Text:      strings.TrimSpace(m.Text.Content),
+		Mentions:  mentions,
+		Timestamp: timestamp,
+		Raw:       raw,
+	}
+}
+
+// OutgoingHandler handles a message posted to an outgoing robot. A non-nil reply
+// is posted to the conversation through the session webhook before the callback
+// is acknowledged.
+type OutgoingHandler func(ctx context.Context, msg *OutgoingMessage) (*types.Message, error)
+
+// NewOutgoingHandler returns an http.Handler for an outgoing (企业内部) robot's
+// message receiving URL. The timestamp and sign headers are verified with secret
+// (the robot's AppSecret, required) and callbacks signed more than an hour away
+// from the local clock are rejected. Replies are posted with httpClient
+// (http.DefaultClient if nil).
+func NewOutgoingHandler(secret string, httpClient *http.Client, handler OutgoingHandler) (http.Handler, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("secret is required to verify callbacks")
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msg, _, ok := readCallback(w, r, secret)
+		if !ok {
+			return
+		}
+
+		reply, err := handler(r.Context(), msg)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if reply != nil {
+			if err := msg.Reply(r.Context(), httpClient, reply, nil); err != nil {
+				http.Error(w, fmt.Sprintf("failed to reply: %v", err), http.StatusBadGateway)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}), nil
+}
+
+// NewCallbackHandler returns an http.Handler for an outgoing (企业内部) robot's
+// message receiving URL that passes messages to handler as types.IncomingMessage.
+// The signature is verified as by NewOutgoingHandler, secret is required.
+func NewCallbackHandler(secret string, handler types.MessageHandler) (http.Handler, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("secret is required to verify callbacks")
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msg, body, ok := readCallback(w, r, secret)
+		if !ok {
+			return
+		}
+
+		if err := handler(r.Context(), msg.toIncoming(body)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}), nil
+}
+
+// readCallback verifies and parses a callback request, answering with an error
+// and returning false when it is rejected
+func readCallback(w http.ResponseWriter, r *http.Request, secret string) (*OutgoingMessage, []byte, bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, nil, false
+	}
+
+	if !verifySignature(secret, r.Header.Get("timestamp"), r.Header.Get("sign")) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxCallbackBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	var msg OutgoingMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		http.Error(w, "invalid callback body", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	return &msg, body, true
+}
+
+// verifySignature checks the sign header of a callback and that its timestamp
+// (milliseconds) is within maxCallbackSkew of the local clock
+func verifySignature(secret, timestamp, sign string) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || sign == "" {
+		return false
+	}
+
+	skew := time.Since(time.UnixMilli(ts))
+	if skew > maxCallbackSkew || skew < -maxCallbackSkew {
+		return false
+	}
+
+	return hmac.Equal([]byte(signature(secret, ts)), []byte(sign))
+}
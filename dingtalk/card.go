@@ -0,0 +1,229 @@
+package dingtalk
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+// Link is a link message: a title, a summary and a picture opening MessageURL when clicked.
+// 参考: https://open.dingtalk.com/document/orgapp/custom-robots-send-group-messages
+type Link struct {
+	Title      string // Title of the message
+	Text       string // Summary shown under the title (too long text is truncated by DingTalk)
+	MessageURL string // URL opened when the message is clicked
+	PicURL     string // Optional: picture shown beside the text
+}
+
+// Validate checks the required fields
+func (l *Link) Validate() error {
+	if l.Title == "" || l.Text == "" || l.MessageURL == "" {
+		return fmt.Errorf("link message requires Title, Text and MessageURL")
+	}
+	return nil
+}
+
+// JSON validates the link and returns the robot payload
+func (l *Link) JSON() ([]byte, error) {
+	if err := l.Validate(); err != nil {
+		return nil, err
+	}
+
+	link := map[string]interface{}{
+		"title":      l.Title,
+		"text":       l.Text,
+		"messageUrl": l.MessageURL,
+	}
+	if l.PicURL != "" {
+		link["picUrl"] = l.PicURL
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"msgtype": "link",
+		"link":    link,
+	})
+}
+
+// Message validates the link and wraps it in a MessageTypeCard message
+func (l *Link) Message() (*types.Message, error) {
+	return payloadMessage(l.JSON())
+}
+
+// ActionButton is a button of an action card
+type ActionButton struct {
+	Title     string
+	ActionURL string
+}
+
+// ActionCard is a markdown card with either a single "read more" button or
+// several independent buttons
+type ActionCard struct {
+	title       string
+	text        string
+	singleTitle string
+	singleURL   string
+	buttons     []ActionButton
+	vertical    bool
+}
+
+// NewActionCard creates an action card. title is shown in the conversation list
+// and notifications, text is the markdown body.
+func NewActionCard(title, text string) *ActionCard {
+	return &ActionCard{title: title, text: text}
+}
+
+// SingleButton makes the whole card a single button opening url
+func (c *ActionCard) SingleButton(title, url string) *ActionCard {
+	c.singleTitle = title
+	c.singleURL = url
+	return c
+}
+
+// AddButton appends an independent button opening url
+func (c *ActionCard) AddButton(title, url string) *ActionCard {
+	c.buttons = append(c.buttons, ActionButton{Title: title, ActionURL: url})
+	return c
+}
+
+// Vertical stacks the buttons vertically instead of side by side
+func (c *ActionCard) Vertical() *ActionCard {
+	c.vertical = true
+	return c
+}
+
+// Validate checks that the card has a body and exactly one kind of button
+func (c *ActionCard) Validate() error {
+	if c.title == "" || c.text == "" {
+		return fmt.Errorf("action card requires a title and a text")
+	}
+
+	single := c.singleTitle != "" || c.singleURL != ""
+	switch {
+	case single && len(c.buttons) > 0:
+		return fmt.Errorf("action card can't have both a single button and independent buttons")
+	case single && (c.singleTitle == "" || c.singleURL == ""):
+		return fmt.Errorf("single button requires a title and a URL")
+	case !single && len(c.buttons) == 0:
+		return fmt.Errorf("action card requires at least one button")
+	}
+
+	for i, btn := range c.buttons {
+		if btn.Title == "" || btn.ActionURL == "" {
+			return fmt.Errorf("button %d requires a title and a URL", i)
+		}
+	}
+	return nil
+}
+
+// JSON validates the card and returns the robot payload
+func (c *ActionCard) JSON() ([]byte, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	card := map[string]interface{}{
+		"title": c.title,
+		"text":  c.text,
+	}
+	if c.singleURL != "" {
+		card["singleTitle"] = c.singleTitle
+		card["singleURL"] = c.singleURL
+	} else {
+		btns := make([]map[string]string, 0, len(c.buttons))
+		for _, btn := range c.buttons {
+			btns = append(btns, map[string]string{"title": btn.Title, "actionURL": btn.ActionURL})
+		}
+		card["btns"] = btns
+	}
+
+	// "0" stacks the buttons vertically, "1" lays them out horizontally
+	card["btnOrientation"] = "1"
+	if c.vertical {
+		card["btnOrientation"] = "0"
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"msgtype":    "actionCard",
+		"actionCard": card,
+	})
+}
+
+// Message validates the card and wraps it in a MessageTypeCard message
+func (c *ActionCard) Message() (*types.Message, error) {
+	return payloadMessage(c.JSON())
+}
+
+// FeedLink is an entry of a feed card
+type FeedLink struct {
+	Title      string
+	MessageURL string
+	PicURL     string
+}
+
+// FeedCard is a list of links, each with a title and a picture
+type FeedCard struct {
+	links []FeedLink
+}
+
+// NewFeedCard creates an empty feed card
+func NewFeedCard() *FeedCard {
+	return &FeedCard{}
+}
+
+// Add appends an entry opening messageURL
+func (c *FeedCard) Add(title, messageURL, picURL string) *FeedCard {
+	c.links = append(c.links, FeedLink{Title: title, MessageURL: messageURL, PicURL: picURL})
+	return c
+}
+
+// Validate checks that the card has entries with a title and a URL
+func (c *FeedCard) Validate() error {
+	if len(c.links) == 0 {
+		return fmt.Errorf("feed card requires at least one link")
+	}
+	for i, link := range c.links {
+		if link.Title == "" || link.MessageURL == "" {
+			return fmt.Errorf("feed link %d requires a title and a URL", i)
+		}
+	}
+	return nil
+}
+
+// JSON validates the card and returns the robot payload
+func (c *FeedCard) JSON() ([]byte, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	links := make([]map[string]string, 0, len(c.links))
+	for _, link := range c.links {
+		links = append(links, map[string]string{
+			"title":      link.Title,
+			"messageURL": link.MessageURL,
+			"picURL":     link.PicURL,
+		})
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"msgtype":  "feedCard",
+		"feedCard": map[string]interface{}{"links": links},
+	})
+}
+
+// Message validates the card and wraps it in a MessageTypeCard message
+func (c *FeedCard) Message() (*types.Message, error) {
+	return payloadMessage(c.JSON())
+}
+
+// payloadMessage wraps a robot payload in a MessageTypeCard message
+func payloadMessage(data []byte, err error) (*types.Message, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.Message{
+		Type:    types.MessageTypeCard,
+		Content: string(data),
+	}, nil
+}
@@ -11,23 +11,44 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/JiSuanSiWeiShiXun/parrot/types"
 )
 
-// Config represents DingTalk robot configuration
+// Config represents DingTalk configuration. A custom robot webhook is used with
+// AccessToken; an enterprise internal app, which can also send private
+// messages, is used with AppKey and AppSecret.
 type Config struct {
-	AccessToken string // Robot webhook access token
-	Secret      string // Optional: secret for signature
-	BaseURL     string // Optional: custom webhook URL
+	AccessToken string             // Robot webhook access token
+	Secret      string             // Optional: secret for signature
+	BaseURL     string             // Optional: custom webhook URL
+	RetryPolicy *types.RetryPolicy // Optional: retry policy for failed sends (default: types.DefaultRetryPolicy())
+	RateLimit   *types.RateLimit   // Optional: client-side rate limit (default: DefaultRateLimit(), &types.RateLimit{} disables it)
+	Concurrency int                // Optional: targets sent to in parallel by SendMessage in app mode (default: 1)
+
+	AppKey     string // App mode: AppKey (Client ID) of the enterprise internal app
+	AppSecret  string // App mode: AppSecret (Client Secret) of the enterprise internal app
+	RobotCode  string // Optional: robot code of the app's robot (default: AppKey)
+	APIBaseURL string // Optional: open platform API base URL for a proxy or test server (default: https://api.dingtalk.com)
 }
 
 // Validate validates the config
 func (c *Config) Validate() error {
+	// App mode: AppKey and AppSecret are required
+	if c.AppKey != "" || c.AppSecret != "" {
+		if c.AppKey == "" {
+			return fmt.Errorf("AppKey is required in app mode")
+		}
+		if c.AppSecret == "" {
+			return fmt.Errorf("AppSecret is required in app mode")
+		}
+		return nil
+	}
 	if c.AccessToken == "" {
-		return fmt.Errorf("AccessToken is required")
+		return fmt.Errorf("AccessToken is required (or provide AppKey and AppSecret for app mode)")
 	}
 	return nil
 }
@@ -37,17 +58,41 @@ func (c *Config) GetPlatform() string {
 	return "dingtalk"
 }
 
+// DefaultRateLimit returns the quotas applied when Config.RateLimit is nil:
+// a webhook robot may send 20 messages per minute. In app mode the send APIs
+// allow 20 calls per second, and a group still takes 20 messages per minute.
+func DefaultRateLimit(app bool) *types.RateLimit {
+	if app {
+		return &types.RateLimit{Rate: 20, Burst: 20, PerTargetRate: types.PerMinute(20), PerTargetBurst: 20}
+	}
+	return &types.RateLimit{Rate: types.PerMinute(20), Burst: 20}
+}
+
+// MessageLimit returns the content length limit of a message type, used to split
+// messages when SendOptions.Split is set: about 20 KB for text and markdown
+func MessageLimit(msgType types.MessageType) types.Limit {
+	return types.Limit{Max: 20000, Unit: types.LengthBytes}
+}
+
 // Client implements IMParrot interface for DingTalk
 type Client struct {
 	config     *Config
 	httpClient *http.Client
 	ownsHTTP   bool // Whether the client owns the http.Client and should close it
+	retry      *types.RetryPolicy
+	limiter    *types.RateLimiter
 	webhookURL string
+	apiBaseURL string
 	closed     bool
 	closedMu   sync.RWMutex
+
+	// App mode access token
+	token       string
+	tokenMu     sync.RWMutex
+	tokenExpiry time.Time
 }
 
-// NewClient creates a new DingTalk robot client
+// NewClient creates a new DingTalk client, in app mode when AppKey is set
 func NewClient(config *Config, httpClient *http.Client) (*Client, error) {
 	ownsHTTP := false
 	if httpClient == nil {
@@ -55,17 +100,44 @@ func NewClient(config *Config, httpClient *http.Client) (*Client, error) {
 		ownsHTTP = true
 	}
 
+	retry := config.RetryPolicy
+	if retry == nil {
+		retry = types.DefaultRetryPolicy()
+	}
+
+	rateLimit := config.RateLimit
+	if rateLimit == nil {
+		rateLimit = DefaultRateLimit(config.AppKey != "")
+	}
+
 	webhookURL := config.BaseURL
 	if webhookURL == "" {
 		webhookURL = "https://oapi.dingtalk.com/robot/send"
 	}
 
-	return &Client{
+	apiBaseURL := strings.TrimRight(config.APIBaseURL, "/")
+	if apiBaseURL == "" {
+		apiBaseURL = defaultAPIBaseURL
+	}
+
+	client := &Client{
 		config:     config,
 		httpClient: httpClient,
 		ownsHTTP:   ownsHTTP,
+		retry:      retry,
+		limiter:    types.NewRateLimiter("dingtalk", rateLimit),
 		webhookURL: webhookURL,
-	}, nil
+		apiBaseURL: apiBaseURL,
+	}
+
+	// Get initial access token only in app mode
+	if config.AppKey != "" {
+		if err := client.refreshToken(context.Background()); err != nil {
+			return nil, fmt.Errorf("failed to get access token: %w", err)
+		}
+	}
+
+	return client, nil
 }
 
 // GetPlatformName returns the platform name
@@ -78,30 +150,120 @@ func (c *Client) sign(timestamp int64) string {
 	if c.config.Secret == "" {
 		return ""
 	}
+	return signature(c.config.Secret, timestamp)
+}
 
-	stringToSign := fmt.Sprintf("%d\n%s", timestamp, c.config.Secret)
-	h := hmac.New(sha256.New, []byte(c.config.Secret))
+// signature computes base64(HMAC-SHA256(secret, "timestamp\nsecret")), used both
+// to sign webhook requests and to verify outgoing robot callbacks
+func signature(secret string, timestamp int64) string {
+	stringToSign := fmt.Sprintf("%d\n%s", timestamp, secret)
+	h := hmac.New(sha256.New, []byte(secret))
 	h.Write([]byte(stringToSign))
 	return base64.StdEncoding.EncodeToString(h.Sum(nil))
 }
 
 // SendMessage sends a message with options (Strategy pattern implementation)
 func (c *Client) SendMessage(ctx context.Context, msg *types.Message, opts *types.SendOptions) error {
+	_, err := c.SendMessageWithResult(ctx, msg, opts)
+	return err
+}
+
+// SendMessageWithResult sends a message and returns its receipts.
+// The robot webhook posts to a single group, so at most one receipt is returned;
+// in app mode every target (a user ID or an openConversationId) gets one.
+func (c *Client) SendMessageWithResult(ctx context.Context, msg *types.Message, opts *types.SendOptions) ([]types.SendResult, error) {
 	if msg == nil || opts == nil {
-		return fmt.Errorf("message and options cannot be nil")
+		return nil, fmt.Errorf("message and options cannot be nil")
 	}
 
-	// DingTalk webhook doesn't support multiple targets, but we still check
-	// Note: For DingTalk, all messages go to the same webhook, so no retry needed for multiple targets
+	if c.config.AppKey != "" {
+		if len(opts.Targets) == 0 {
+			return nil, fmt.Errorf("at least one target is required")
+		}
 
-	// Build webhook URL with signature
-	timestamp := time.Now().UnixMilli()
-	webhookURL := fmt.Sprintf("%s?access_token=%s", c.webhookURL, c.config.AccessToken)
+		mentions, err := mentionsFrom(opts, msg.Type)
+		if err != nil {
+			return nil, err
+		}
+		if mentions != nil {
+			return nil, fmt.Errorf("mentions are only supported by webhook robots")
+		}
 
-	if c.config.Secret != "" {
-		sign := c.sign(timestamp)
-		webhookURL = fmt.Sprintf("%s&timestamp=%d&sign=%s",
-			webhookURL, timestamp, url.QueryEscape(sign))
+		concurrency := opts.Concurrency
+		if concurrency <= 0 {
+			concurrency = c.config.Concurrency
+		}
+
+		// Fan out to the targets, retrying each (part) according to the retry policy.
+		// The message is converted before the first attempt, retrying can't fix it.
+		return types.FanOut(ctx, opts.Targets, concurrency, func(ctx context.Context, target types.Target) (*types.SendResult, error) {
+			return types.SendSplit(ctx, msg, opts, MessageLimit(msg.Type), func(ctx context.Context, msg *types.Message) (*types.SendResult, error) {
+				msgKey, msgParam, err := appMessage(msg)
+				if err != nil {
+					return nil, err
+				}
+
+				var result *types.SendResult
+				err = c.retry.Do(ctx, func(ctx context.Context) error {
+					var err error
+					result, err = c.sendViaApp(ctx, msgKey, msgParam, target)
+					return err
+				})
+				return result, err
+			})
+		})
+	}
+
+	// DingTalk webhook doesn't support multiple targets: all messages go to the
+	// group the robot belongs to, so the message (or each part) is sent and retried once
+	result, err := types.SendSplit(ctx, msg, opts, MessageLimit(msg.Type), func(ctx context.Context, msg *types.Message) (*types.SendResult, error) {
+		return c.send(ctx, msg, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []types.SendResult{*result}, nil
+}
+
+// send builds the robot request for a message and delivers it, retrying according to the retry policy
+func (c *Client) send(ctx context.Context, msg *types.Message, opts *types.SendOptions) (*types.SendResult, error) {
+	reqBody, err := robotBody(msg, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	var respBody []byte
+	err = c.retry.Do(ctx, func(ctx context.Context) error {
+		var err error
+		respBody, err = c.post(ctx, body)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// DingTalk webhook robots don't return a message ID
+	var target types.Target
+	if len(opts.Targets) > 0 {
+		target = opts.Targets[0]
+	}
+	return &types.SendResult{
+		Target:    target,
+		Timestamp: time.Now(),
+		Raw:       respBody,
+	}, nil
+}
+
+// robotBody builds the request body of a robot webhook or session webhook for a message
+func robotBody(msg *types.Message, opts *types.SendOptions) (map[string]interface{}, error) {
+	mentions, err := mentionsFrom(opts, msg.Type)
+	if err != nil {
+		return nil, err
 	}
 
 	// Build request body based on message type
@@ -117,27 +279,34 @@ func (c *Client) SendMessage(ctx context.Context, msg *types.Message, opts *type
 		}
 
 		// Add @ mentions for group messages
-		if len(opts.AtUsers) > 0 {
-			reqBody["at"] = map[string]interface{}{
-				"atMobiles": opts.AtUsers,
-				"isAtAll":   false,
-			}
+		if mentions != nil {
+			reqBody["at"] = mentions.at()
 		}
 
 	case types.MessageTypeMarkdown:
+		text := msg.Content
+		if mentions != nil {
+			text = mentions.appendTokens(text)
+		}
 		reqBody = map[string]interface{}{
 			"msgtype": "markdown",
 			"markdown": map[string]interface{}{
 				"title": "Message",
-				"text":  msg.Content,
+				"text":  text,
 			},
 		}
 
-		if len(opts.AtUsers) > 0 {
-			reqBody["at"] = map[string]interface{}{
-				"atMobiles": opts.AtUsers,
-				"isAtAll":   false,
-			}
+		if mentions != nil {
+			reqBody["at"] = mentions.at()
+		}
+
+	case types.MessageTypeCard:
+		// Content is a complete link, actionCard or feedCard payload, e.g. built with NewActionCard
+		if err := json.Unmarshal([]byte(msg.Content), &reqBody); err != nil {
+			return nil, types.Permanent(fmt.Errorf("invalid card JSON: %w", err))
+		}
+		if _, ok := reqBody["msgtype"].(string); !ok {
+			return nil, types.Permanent(fmt.Errorf("card JSON must be a robot payload with a msgtype"))
 		}
 
 	default:
@@ -147,28 +316,54 @@ func (c *Client) SendMessage(ctx context.Context, msg *types.Message, opts *type
 				"content": msg.Content,
 			},
 		}
+
+		if mentions != nil {
+			reqBody["at"] = mentions.at()
+		}
 	}
 
-	body, err := json.Marshal(reqBody)
-	if err != nil {
-		return err
+	return reqBody, nil
+}
+
+// post delivers a request body to the robot webhook.
+// The signature is computed per call so that retried requests carry a fresh timestamp.
+func (c *Client) post(ctx context.Context, body []byte) ([]byte, error) {
+	// The robot has a single quota for its group
+	if err := c.limiter.Wait(ctx, ""); err != nil {
+		return nil, err
+	}
+
+	// Build webhook URL with signature
+	timestamp := time.Now().UnixMilli()
+	webhookURL := fmt.Sprintf("%s?access_token=%s", c.webhookURL, c.config.AccessToken)
+
+	if c.config.Secret != "" {
+		sign := c.sign(timestamp)
+		webhookURL = fmt.Sprintf("%s&timestamp=%d&sign=%s",
+			webhookURL, timestamp, url.QueryEscape(sign))
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewReader(body))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
+	return readRobotResponse(resp)
+}
+
+// readRobotResponse reads the {"errcode", "errmsg"} response of a robot webhook
+// or session webhook and returns the raw body
+func readRobotResponse(resp *http.Response) ([]byte, error) {
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var apiResp struct {
@@ -177,28 +372,54 @@ func (c *Client) SendMessage(ctx context.Context, msg *types.Message, opts *type
 	}
 
 	if err := json.Unmarshal(respBody, &apiResp); err != nil {
-		return err
+		return nil, err
 	}
 
 	if apiResp.ErrCode != 0 {
-		return fmt.Errorf("dingtalk API error: %s", apiResp.ErrMsg)
+		return nil, newAPIError(resp, apiResp.ErrCode, apiResp.ErrMsg)
 	}
 
-	return nil
+	return respBody, nil
 }
 
-// SendPrivateMessage sends a private message (DingTalk robot doesn't support private messages directly)
+// SendPrivateMessage sends a private message to a user ID in app mode
+// (webhook robots don't support private messages)
 func (c *Client) SendPrivateMessage(ctx context.Context, userID string, msg *types.Message) error {
-	return fmt.Errorf("dingtalk robot does not support private messages")
+	if c.config.AppKey == "" {
+		return fmt.Errorf("dingtalk robot does not support private messages")
+	}
+	return c.SendMessage(ctx, msg, &types.SendOptions{
+		Targets: []types.Target{{ID: userID, ChatType: types.ChatTypePrivate}},
+	})
 }
 
-// SendGroupMessage sends a message to a group
+// SendGroupMessage sends a message to a group: the robot's own group for webhook
+// robots, the openConversationId in app mode
 func (c *Client) SendGroupMessage(ctx context.Context, groupID string, msg *types.Message) error {
 	return c.SendMessage(ctx, msg, &types.SendOptions{
 		Targets: []types.Target{{ID: groupID, ChatType: types.ChatTypeGroup}},
 	})
 }
 
+// UpdateMessage is not supported: neither webhook robot messages nor the
+// template messages of app robots can be edited after sending
+func (c *Client) UpdateMessage(ctx context.Context, receipt *types.SendResult, msg *types.Message) error {
+	return &types.UnsupportedError{Platform: "dingtalk", Operation: "UpdateMessage"}
+}
+
+// RecallMessage withdraws a message sent in app mode, using the processQueryKey
+// of its receipt. Messages of webhook robots can't be withdrawn through the API.
+func (c *Client) RecallMessage(ctx context.Context, receipt *types.SendResult) error {
+	if receipt == nil || receipt.MessageID == "" {
+		return fmt.Errorf("receipt with message ID cannot be nil")
+	}
+	if c.config.AppKey == "" {
+		return &types.UnsupportedError{Platform: "dingtalk webhook", Operation: "RecallMessage"}
+	}
+
+	return c.recallViaApp(ctx, receipt)
+}
+
 // Close releases all resources held by the client
 func (c *Client) Close() error {
 	c.closedMu.Lock()
@@ -215,5 +436,10 @@ func (c *Client) Close() error {
 		c.httpClient.CloseIdleConnections()
 	}
 
+	// Clear token
+	c.tokenMu.Lock()
+	c.token = ""
+	c.tokenMu.Unlock()
+
 	return nil
 }
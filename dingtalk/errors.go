@@ -0,0 +1,77 @@
+package dingtalk
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+// errorKinds maps DingTalk robot errcodes to error kinds
+var errorKinds = map[int]types.ErrorKind{
+	-1:     types.ErrorKindServer,         // system busy
+	130101: types.ErrorKindRateLimited,    // send too fast
+	410100: types.ErrorKindRateLimited,    // send too fast, exceed 20 times per minute
+	300001: types.ErrorKindAuth,           // access_token doesn't exist
+	300005: types.ErrorKindAuth,           // access_token doesn't exist
+	310000: types.ErrorKindAuth,           // security settings rejected the message (sign, keywords or ip)
+	400102: types.ErrorKindAuth,           // robot is stopped
+	400105: types.ErrorKindInvalidRequest, // unsupported message type
+	40035:  types.ErrorKindInvalidRequest, // missing parameter
+}
+
+// newAPIError builds a typed error from a DingTalk response
+func newAPIError(resp *http.Response, errCode int, errMsg string) *types.APIError {
+	kind, ok := errorKinds[errCode]
+	if !ok && resp.StatusCode >= http.StatusInternalServerError {
+		kind = types.ErrorKindServer
+	}
+
+	return &types.APIError{
+		Platform:   "dingtalk",
+		HTTPStatus: resp.StatusCode,
+		Code:       errCode,
+		Message:    errMsg,
+		RequestID:  resp.Header.Get("X-Acs-Request-Id"),
+		Kind:       kind,
+	}
+}
+
+// appErrorKinds maps the string error codes of the open platform API, used in
+// app mode, to error kinds
+var appErrorKinds = map[string]types.ErrorKind{
+	"InvalidAuthentication":                              types.ErrorKindTokenExpired,   // access token is invalid or expired
+	"Forbidden.AccessDenied.AccessTokenPermissionDenied": types.ErrorKindAuth,           // app lacks the required permission
+	"Forbidden.AccessDenied.QpsLimitForApi":              types.ErrorKindRateLimited,    // api qps limit exceeded
+	"Forbidden.AccessDenied.QpsLimitForAppkeyAndApi":     types.ErrorKindRateLimited,    // app qps limit of the api exceeded
+	"invalidClientIdOrSecret":                            types.ErrorKindAuth,           // invalid AppKey or AppSecret
+	"Throttling":                                         types.ErrorKindRateLimited,    // request throttled
+	"invalidParameter":                                   types.ErrorKindInvalidRequest, // invalid request parameter
+}
+
+// appAPIError builds a typed error from an open platform response and invalidates
+// the cached access token when it was rejected, so that a retry fetches a new one
+func (c *Client) appAPIError(resp *http.Response, code, msg, requestID string) *types.APIError {
+	kind, ok := appErrorKinds[code]
+	if !ok && resp.StatusCode >= http.StatusInternalServerError {
+		kind = types.ErrorKindServer
+	}
+
+	if kind == types.ErrorKindTokenExpired {
+		c.tokenMu.Lock()
+		c.tokenExpiry = time.Time{}
+		c.tokenMu.Unlock()
+	}
+
+	if requestID == "" {
+		requestID = resp.Header.Get("X-Acs-Request-Id")
+	}
+	return &types.APIError{
+		Platform:   "dingtalk",
+		HTTPStatus: resp.StatusCode,
+		Message:    fmt.Sprintf("%s: %s", code, msg),
+		RequestID:  requestID,
+		Kind:       kind,
+	}
+}
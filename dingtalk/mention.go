@@ -0,0 +1,109 @@
+package dingtalk
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+// MentionsKey is the SendOptions.Extra key holding the Mentions (or *Mentions) of a message
+const MentionsKey = "dingtalk_mentions"
+
+// Mentions lists who a text or markdown message @-mentions in the robot's group.
+// SendOptions.AtUsers is still accepted and treated as mobiles.
+// 参考: https://open.dingtalk.com/document/orgapp/custom-robots-send-group-messages
+type Mentions struct {
+	Mobiles []string // Mobile numbers of the users to mention
+	UserIDs []string // DingTalk user IDs (userid) of the users to mention
+	All     bool     // @所有人, can't be combined with Mobiles or UserIDs
+}
+
+// Validate checks that the mentions are not empty strings and that @all is not
+// combined with individual users
+func (m *Mentions) Validate() error {
+	if m.All && (len(m.Mobiles) > 0 || len(m.UserIDs) > 0) {
+		return fmt.Errorf("@all can't be combined with mobiles or user IDs")
+	}
+	for _, mobile := range m.Mobiles {
+		if strings.TrimSpace(mobile) == "" {
+			return fmt.Errorf("mention mobile cannot be empty")
+		}
+	}
+	for _, userID := range m.UserIDs {
+		if strings.TrimSpace(userID) == "" {
+			return fmt.Errorf("mention user ID cannot be empty")
+		}
+	}
+	return nil
+}
+
+// empty reports whether nobody is mentioned
+func (m *Mentions) empty() bool {
+	return !m.All && len(m.Mobiles) == 0 && len(m.UserIDs) == 0
+}
+
+// at returns the "at" block of the robot request
+func (m *Mentions) at() map[string]interface{} {
+	at := map[string]interface{}{
+		"isAtAll": m.All,
+	}
+	if len(m.Mobiles) > 0 {
+		at["atMobiles"] = m.Mobiles
+	}
+	if len(m.UserIDs) > 0 {
+		at["atUserIds"] = m.UserIDs
+	}
+	return at
+}
+
+// appendTokens appends the @mobile and @userId tokens missing from a markdown text.
+// Unlike text messages, markdown only highlights and notifies users whose token
+// appears in the text.
+func (m *Mentions) appendTokens(text string) string {
+	tokens := make([]string, 0, len(m.Mobiles)+len(m.UserIDs))
+	for _, id := range append(append([]string{}, m.Mobiles...), m.UserIDs...) {
+		if token := "@" + id; !strings.Contains(text, token) {
+			tokens = append(tokens, token)
+		}
+	}
+	if len(tokens) == 0 {
+		return text
+	}
+	return strings.TrimRight(text, "\n") + "\n\n" + strings.Join(tokens, " ")
+}
+
+// mentionsFrom merges opts.AtUsers and the Mentions in opts.Extra and validates
+// them for msgType. It returns nil when nobody is mentioned.
+func mentionsFrom(opts *types.SendOptions, msgType types.MessageType) (*Mentions, error) {
+	m := &Mentions{}
+	switch v := opts.Extra[MentionsKey].(type) {
+	case nil:
+	case Mentions:
+		m = &v
+	case *Mentions:
+		if v != nil {
+			m = v
+		}
+	default:
+		return nil, fmt.Errorf("SendOptions.Extra[%q] must be dingtalk.Mentions, got %T", MentionsKey, v)
+	}
+
+	if len(opts.AtUsers) > 0 {
+		// Don't modify the caller's Mentions
+		merged := *m
+		merged.Mobiles = append(append([]string{}, m.Mobiles...), opts.AtUsers...)
+		m = &merged
+	}
+
+	if m.empty() {
+		return nil, nil
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	if msgType == types.MessageTypeCard {
+		return nil, fmt.Errorf("mentions are only supported for text and markdown messages")
+	}
+	return m, nil
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/JiSuanSiWeiShiXun/parrot/telegram"
 	"github.com/JiSuanSiWeiShiXun/parrot/types"
 	"github.com/JiSuanSiWeiShiXun/parrot/wechat"
+	"github.com/JiSuanSiWeiShiXun/parrot/wpsxz"
 )
 
 // Platform constants
@@ -42,12 +43,36 @@ func NewIMClient(platform string, config types.Config) (types.IMParrot, error) {
 		Timeout: 30 * time.Second,
 	}
 
-	return createClientWithHTTP(platform, config, httpClient)
+	return createClientWithHTTP(platform, config, httpClient, nil)
+}
+
+// clientDefaults holds settings applied to configs that leave them unset
+// (used by ClientPool to share policies across all of its clients)
+type clientDefaults struct {
+	retryPolicy *types.RetryPolicy
+	concurrency int
+}
+
+// retry returns the configured policy, or the default when it is unset
+func (d *clientDefaults) retry(policy *types.RetryPolicy) *types.RetryPolicy {
+	if policy != nil || d == nil {
+		return policy
+	}
+	return d.retryPolicy
+}
+
+// fanOut returns the configured concurrency, or the default when it is unset
+func (d *clientDefaults) fanOut(concurrency int) int {
+	if concurrency > 0 || d == nil {
+		return concurrency
+	}
+	return d.concurrency
 }
 
 // createClientWithHTTP creates a client with a specific HTTP client
 // This is used by both NewIMClient and ClientPool
-func createClientWithHTTP(platform string, config types.Config, httpClient *http.Client) (types.IMParrot, error) {
+// The config is copied before defaults are applied, so the caller's config is never modified
+func createClientWithHTTP(platform string, config types.Config, httpClient *http.Client, defaults *clientDefaults) (types.IMParrot, error) {
 	// Factory method - create different implementations based on platform
 	switch platform {
 	case PlatformLark:
@@ -55,28 +80,50 @@ func createClientWithHTTP(platform string, config types.Config, httpClient *http
 		if !ok {
 			return nil, fmt.Errorf("invalid config type for lark platform")
 		}
-		return lark.NewClient(cfg, httpClient)
+		c := *cfg
+		c.RetryPolicy = defaults.retry(c.RetryPolicy)
+		c.Concurrency = defaults.fanOut(c.Concurrency)
+		return lark.NewClient(&c, httpClient)
 
 	case PlatformTelegram:
 		cfg, ok := config.(*telegram.Config)
 		if !ok {
 			return nil, fmt.Errorf("invalid config type for telegram platform")
 		}
-		return telegram.NewClient(cfg, httpClient)
+		c := *cfg
+		c.RetryPolicy = defaults.retry(c.RetryPolicy)
+		c.Concurrency = defaults.fanOut(c.Concurrency)
+		return telegram.NewClient(&c, httpClient)
 
 	case PlatformDingTalk:
 		cfg, ok := config.(*dingtalk.Config)
 		if !ok {
 			return nil, fmt.Errorf("invalid config type for dingtalk platform")
 		}
-		return dingtalk.NewClient(cfg, httpClient)
+		c := *cfg
+		c.RetryPolicy = defaults.retry(c.RetryPolicy)
+		c.Concurrency = defaults.fanOut(c.Concurrency)
+		return dingtalk.NewClient(&c, httpClient)
 
 	case PlatformWeChat:
 		cfg, ok := config.(*wechat.Config)
 		if !ok {
 			return nil, fmt.Errorf("invalid config type for wechat platform")
 		}
-		return wechat.NewClient(cfg, httpClient)
+		c := *cfg
+		c.RetryPolicy = defaults.retry(c.RetryPolicy)
+		c.Concurrency = defaults.fanOut(c.Concurrency)
+		return wechat.NewClient(&c, httpClient)
+
+	case PlatformWPSXZ:
+		cfg, ok := config.(*wpsxz.Config)
+		if !ok {
+			return nil, fmt.Errorf("invalid config type for wpsxz platform")
+		}
+		c := *cfg
+		c.RetryPolicy = defaults.retry(c.RetryPolicy)
+		c.Concurrency = defaults.fanOut(c.Concurrency)
+		return wpsxz.NewClient(&c, httpClient)
 
 	default:
 		return nil, fmt.Errorf("unsupported platform: %s", platform)
@@ -117,6 +164,15 @@ func NewDingTalkClient(accessToken, secret string) (types.IMParrot, error) {
 	return NewIMClient(PlatformDingTalk, config)
 }
 
+// NewDingTalkAppClient is a convenience method for creating DingTalk enterprise internal app client
+func NewDingTalkAppClient(appKey, appSecret string) (types.IMParrot, error) {
+	config := &dingtalk.Config{
+		AppKey:    appKey,
+		AppSecret: appSecret,
+	}
+	return NewIMClient(PlatformDingTalk, config)
+}
+
 // NewWeChatClient is a convenience method for creating WeChat Work client
 func NewWeChatClient(corpID, corpSecret string) (types.IMParrot, error) {
 	config := &wechat.Config{
@@ -125,3 +181,12 @@ func NewWeChatClient(corpID, corpSecret string) (types.IMParrot, error) {
 	}
 	return NewIMClient(PlatformWeChat, config)
 }
+
+// NewWPSXZClient is a convenience method for creating WPS Xiezuo client
+func NewWPSXZClient(appID, appSecret string) (types.IMParrot, error) {
+	config := &wpsxz.Config{
+		AppID:     appID,
+		AppSecret: appSecret,
+	}
+	return NewIMClient(PlatformWPSXZ, config)
+}
@@ -1,12 +1,35 @@
 package imparrot_test
 
 import (
+	"bytes"
 	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	imparrot "github.com/JiSuanSiWeiShiXun/parrot"
+	"github.com/JiSuanSiWeiShiXun/parrot/dingtalk"
+	"github.com/JiSuanSiWeiShiXun/parrot/lark"
+	"github.com/JiSuanSiWeiShiXun/parrot/parrottest"
 	"github.com/JiSuanSiWeiShiXun/parrot/telegram"
 	"github.com/JiSuanSiWeiShiXun/parrot/types"
+	"github.com/JiSuanSiWeiShiXun/parrot/wechat"
+	"github.com/JiSuanSiWeiShiXun/parrot/wpsxz"
 )
 
 // TestStrategyPattern demonstrates the strategy pattern
@@ -50,6 +73,22 @@ func TestFactoryMethod(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name:     "wpsxz missing app secret",
+			platform: imparrot.PlatformWPSXZ,
+			config: &wpsxz.Config{
+				AppID: "test-app",
+			},
+			wantErr: true,
+		},
+		{
+			name:     "platform mismatch",
+			platform: imparrot.PlatformWPSXZ,
+			config: &telegram.Config{
+				BotToken: "test-token",
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -114,6 +153,1312 @@ func TestSendOptions(t *testing.T) {
 	}
 }
 
+// TestSendMessageWithResult verifies that receipts carry the platform message ID
+func TestSendMessageWithResult(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":42,"date":1700000000,"chat":{"id":-1001}}}`))
+	}))
+	defer server.Close()
+
+	client, err := imparrot.NewIMClient(imparrot.PlatformTelegram, &telegram.Config{
+		BotToken: "test-token",
+		BaseURL:  server.URL + "/bot",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	results, err := client.SendMessageWithResult(context.Background(),
+		&types.Message{Type: types.MessageTypeText, Content: "hello"},
+		&types.SendOptions{Targets: []types.Target{{ID: "-1001", ChatType: types.ChatTypeGroup}}})
+	if err != nil {
+		t.Fatalf("SendMessageWithResult() error = %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+	if results[0].MessageID != "42" || results[0].ChatID != "-1001" {
+		t.Errorf("Unexpected receipt: %+v", results[0])
+	}
+	if results[0].Timestamp.Unix() != 1700000000 {
+		t.Errorf("Expected platform timestamp, got %v", results[0].Timestamp)
+	}
+}
+
+// TestMessageEditor verifies the edit/recall capability and the unsupported error
+func TestMessageEditor(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.URL.Path)
+		_, _ = w.Write([]byte(`{"ok":true,"result":true}`))
+	}))
+	defer server.Close()
+
+	client, err := imparrot.NewIMClient(imparrot.PlatformTelegram, &telegram.Config{
+		BotToken: "test-token",
+		BaseURL:  server.URL + "/bot",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	editor, ok := client.(imparrot.MessageEditor)
+	if !ok {
+		t.Fatal("Telegram client should implement MessageEditor")
+	}
+	receipt := &types.SendResult{MessageID: "42", ChatID: "-1001"}
+	if err := editor.UpdateMessage(context.Background(), receipt, &types.Message{Type: types.MessageTypeText, Content: "resolved"}); err != nil {
+		t.Errorf("UpdateMessage() error = %v", err)
+	}
+	if err := editor.RecallMessage(context.Background(), receipt); err != nil {
+		t.Errorf("RecallMessage() error = %v", err)
+	}
+	photo := &types.Message{Type: types.MessageTypeImage, Data: map[string]interface{}{"caption": "resolved"}}
+	if err := editor.UpdateMessage(context.Background(), receipt, photo); err != nil {
+		t.Errorf("UpdateMessage() of a photo error = %v", err)
+	}
+	sticker := &types.Message{Type: types.MessageTypeSticker, Content: "sticker-id"}
+	if err := editor.UpdateMessage(context.Background(), receipt, sticker); !errors.Is(err, types.ErrUnsupported) {
+		t.Errorf("UpdateMessage() of a sticker error = %v, want ErrUnsupported", err)
+	}
+	want := []string{"/bottest-token/editMessageText", "/bottest-token/deleteMessage", "/bottest-token/editMessageCaption"}
+	if strings.Join(methods, ",") != strings.Join(want, ",") {
+		t.Errorf("Unexpected API calls: %v", methods)
+	}
+
+	dingTalk, err := imparrot.NewDingTalkClient("test-token", "")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	err = dingTalk.(imparrot.MessageEditor).RecallMessage(context.Background(), receipt)
+	if !errors.Is(err, types.ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported from dingtalk, got %v", err)
+	}
+}
+
+// TestBaseURL tests that Lark and WeChat Work endpoints are derived from Config.BaseURL
+func TestBaseURL(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		paths []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/open-apis/auth/v3/tenant_access_token/internal":
+			_, _ = w.Write([]byte(`{"code":0,"tenant_access_token":"t","expire":7200}`))
+		case "/open-apis/im/v1/messages":
+			_, _ = w.Write([]byte(`{"code":0,"data":{"message_id":"om_1"}}`))
+		case "/cgi-bin/gettoken":
+			_, _ = w.Write([]byte(`{"errcode":0,"access_token":"t","expires_in":7200}`))
+		case "/cgi-bin/message/send":
+			_, _ = w.Write([]byte(`{"errcode":0,"msgid":"m1"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	msg := &types.Message{Type: types.MessageTypeText, Content: "hello"}
+
+	larkClient, err := imparrot.NewIMClient(imparrot.PlatformLark, &lark.Config{
+		AppID:     "app",
+		AppSecret: "secret",
+		BaseURL:   server.URL + "/",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create lark client: %v", err)
+	}
+	if err := larkClient.SendPrivateMessage(context.Background(), "ou_1", msg); err != nil {
+		t.Errorf("lark SendPrivateMessage() error = %v", err)
+	}
+
+	wechatClient, err := imparrot.NewIMClient(imparrot.PlatformWeChat, &wechat.Config{
+		CorpID:     "corp",
+		CorpSecret: "secret",
+		AgentID:    1,
+		BaseURL:    server.URL,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create wechat client: %v", err)
+	}
+	if err := wechatClient.SendPrivateMessage(context.Background(), "user", msg); err != nil {
+		t.Errorf("wechat SendPrivateMessage() error = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(paths) != 4 {
+		t.Errorf("Expected 4 requests to the test server, got %v", paths)
+	}
+}
+
+// TestLarkUpload tests uploading media and sending it in one call
+func TestLarkUpload(t *testing.T) {
+	server := parrottest.NewLarkServer()
+	defer server.Close()
+
+	client, err := lark.NewClient(server.Config(), nil)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	// A rate limited upload is retried with the same content
+	server.InjectRateLimit("/im/v1/images", 0)
+	imageKey, err := client.UploadImage(context.Background(), strings.NewReader("png"), "")
+	if err != nil || imageKey == "" {
+		t.Fatalf("UploadImage() = %q, %v", imageKey, err)
+	}
+	if uploads := server.RequestsTo("/im/v1/images"); len(uploads) != 2 || !bytes.Contains(uploads[1].Body, []byte("png")) {
+		t.Errorf("Expected the upload to be retried with its content, got %d requests", len(uploads))
+	}
+
+	if _, err := client.UploadImage(context.Background(), strings.NewReader(""), ""); err == nil {
+		t.Error("Expected an empty upload to fail")
+	}
+
+	opts := &types.SendOptions{Targets: []types.Target{{ID: "oc_1", ChatType: types.ChatTypeGroup}}}
+	results, err := client.SendFileFromReader(context.Background(), strings.NewReader("report"), "report.pdf", opts)
+	if err != nil || len(results) != 1 {
+		t.Fatalf("SendFileFromReader() = %v, %v", results, err)
+	}
+
+	upload := server.RequestsTo("/im/v1/files")[0]
+	if !bytes.Contains(upload.Body, []byte(`name="file_type"`+"\r\n\r\npdf")) {
+		t.Errorf("Expected file_type pdf in the upload, got %s", upload.Body)
+	}
+	sends := server.RequestsTo("/im/v1/messages")
+	var sent struct {
+		MsgType string `json:"msg_type"`
+		Content string `json:"content"`
+	}
+	_ = json.Unmarshal(sends[len(sends)-1].Body, &sent)
+	if sent.MsgType != "file" || !strings.Contains(sent.Content, `"file_key":"file_`) {
+		t.Errorf("Expected a file message with the uploaded key, got %+v", sent)
+	}
+
+	webhook, _ := lark.NewClient(server.WebhookConfig(), nil)
+	if _, err := webhook.UploadImage(context.Background(), strings.NewReader("png"), ""); !errors.Is(err, types.ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported in webhook mode, got %v", err)
+	}
+}
+
+// TestTelegramMedia tests media messages by URL and by multipart upload, and albums
+func TestTelegramMedia(t *testing.T) {
+	server := parrottest.NewTelegramServer()
+	defer server.Close()
+
+	client, err := telegram.NewClient(server.Config(), nil)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	photo := &types.Message{Type: types.MessageTypeImage, Content: "https://example.com/cat.png", Data: map[string]interface{}{"caption": "cat"}}
+	if err := client.SendPrivateMessage(context.Background(), "1", photo); err != nil {
+		t.Fatalf("Failed to send photo: %v", err)
+	}
+	if reqs := server.RequestsTo("/sendPhoto"); len(reqs) != 1 || !bytes.Contains(reqs[0].Body, []byte(`"photo":"https://example.com/cat.png"`)) {
+		t.Errorf("Expected a JSON sendPhoto request, got %v", reqs)
+	}
+
+	// The reader is uploaded to every target, and again when retried
+	server.InjectRateLimit("/sendDocument", 0)
+	document := &types.Message{
+		Type: types.MessageTypeFile,
+		Data: map[string]interface{}{telegram.UploadKey: strings.NewReader("report body"), "caption": "*report*", "parse_mode": "MarkdownV2"},
+	}
+	opts := &types.SendOptions{Targets: []types.Target{{ID: "1"}, {ID: "2"}}}
+	if _, err := client.SendMessageWithResult(context.Background(), document, opts); err != nil {
+		t.Fatalf("Failed to send document: %v", err)
+	}
+	reqs := server.RequestsTo("/sendDocument")
+	if len(reqs) != 3 {
+		t.Fatalf("Expected 3 sendDocument requests, got %d", len(reqs))
+	}
+	for _, req := range reqs {
+		if !strings.HasPrefix(req.Header.Get("Content-Type"), "multipart/form-data") || !bytes.Contains(req.Body, []byte("report body")) {
+			t.Errorf("Expected a multipart upload of the document, got %s", req.Body)
+		}
+	}
+
+	album := []telegram.InputMedia{
+		{Type: telegram.InputMediaPhoto, Media: "file-id-1", Caption: "first"},
+		{Type: telegram.InputMediaPhoto, File: &telegram.InputFile{Name: "b.png", Data: []byte("png")}},
+	}
+	results, err := client.SendMediaGroup(context.Background(), album, &types.SendOptions{Targets: []types.Target{{ID: "1"}}})
+	if err != nil || len(results) != 1 || results[0].MessageID == "" {
+		t.Fatalf("SendMediaGroup() = %v, %v", results, err)
+	}
+	if req := server.RequestsTo("/sendMediaGroup")[0]; !bytes.Contains(req.Body, []byte("attach://file1")) {
+		t.Errorf("Expected the upload to be attached, got %s", req.Body)
+	}
+
+	if _, err := client.SendMediaGroup(context.Background(), album[:1], &types.SendOptions{Targets: []types.Target{{ID: "1"}}}); err == nil {
+		t.Error("Expected a single item album to be rejected")
+	}
+}
+
+// TestTelegramCallbackQuery tests inline keyboards and answering their callback queries
+func TestTelegramCallbackQuery(t *testing.T) {
+	server := parrottest.NewTelegramServer()
+	defer server.Close()
+
+	client, err := telegram.NewClient(server.Config(), nil)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	keyboard := telegram.NewInlineKeyboard().Row(
+		telegram.CallbackButton("Acknowledge", "ack:42"),
+		telegram.CallbackButton("Silence", "silence:42"),
+	)
+	alert := &types.Message{Type: types.MessageTypeText, Content: "disk full", Data: map[string]interface{}{"reply_markup": keyboard}}
+	if err := client.SendGroupMessage(context.Background(), "-100", alert); err != nil {
+		t.Fatalf("Failed to send alert: %v", err)
+	}
+	if req := server.RequestsTo("/sendMessage")[0]; !bytes.Contains(req.Body, []byte(`"callback_data":"ack:42"`)) {
+		t.Errorf("Expected the inline keyboard in the request, got %s", req.Body)
+	}
+
+	var messages int
+	handler := telegram.NewWebhookHandler("", telegram.MultiHandler(
+		telegram.HandleMessages(func(ctx context.Context, msg *types.IncomingMessage) error {
+			messages++
+			return nil
+		}),
+		client.HandleCallbackQueries(func(ctx context.Context, query *telegram.CallbackQuery) (*telegram.CallbackResponse, error) {
+			if query.Data != "ack:42" {
+				t.Errorf("Unexpected callback data %q", query.Data)
+			}
+			return &telegram.CallbackResponse{
+				Text:        "Acknowledged",
+				Edit:        &types.Message{Type: types.MessageTypeText, Content: "disk full (acknowledged by " + query.From.FirstName + ")"},
+				ReplyMarkup: telegram.NewInlineKeyboard(),
+			}, nil
+		}),
+	))
+
+	body := `{"update_id":1,"callback_query":{"id":"q1","from":{"id":7,"first_name":"Ada"},` +
+		`"message":{"message_id":1,"chat":{"id":-100,"type":"group"},"date":1700000000,"text":"disk full"},"data":"ack:42"}}`
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/telegram", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+
+	if messages != 0 {
+		t.Errorf("Expected the message handler to ignore callback queries, got %d calls", messages)
+	}
+	if reqs := server.RequestsTo("/answerCallbackQuery"); len(reqs) != 1 || !bytes.Contains(reqs[0].Body, []byte(`"text":"Acknowledged"`)) {
+		t.Errorf("Expected the callback query to be answered, got %v", reqs)
+	}
+	reqs := server.RequestsTo("/editMessageText")
+	if len(reqs) != 1 || !bytes.Contains(reqs[0].Body, []byte(`"reply_markup":{"inline_keyboard":[]}`)) ||
+		!bytes.Contains(reqs[0].Body, []byte("acknowledged by Ada")) {
+		t.Errorf("Expected the message to be edited and its keyboard removed, got %v", reqs)
+	}
+}
+
+// TestTelegramMarkdown tests converting common markdown to Telegram's parse modes
+func TestTelegramMarkdown(t *testing.T) {
+	tests := []struct {
+		name       string
+		markdown   string
+		markdownV2 string
+		html       string
+	}{
+		{"plain text", "CPU > 90%! (web-01.prod)", `CPU \> 90%\! \(web\-01\.prod\)`, "CPU &gt; 90%! (web-01.prod)"},
+		{"emphasis", "**bold *nested*** _it_ ~~old~~", "*bold _nested_* _it_ ~old~", "<b>bold <i>nested</i></b> <i>it</i> <s>old</s>"},
+		{"snake case", "disk_usage_pct", `disk\_usage\_pct`, "disk_usage_pct"},
+		{"code", "run `a_b.sh`", "run `a_b.sh`", "run <code>a_b.sh</code>"},
+		{"link", "[run-book](https://x.io/a_(b))", `[run\-book](https://x.io/a_(b\))`, `<a href="https://x.io/a_(b)">run-book</a>`},
+		{"heading and list", "# Alert\n- one\n2. two", "*Alert*\n• one\n2\\. two", "<b>Alert</b>\n• one\n2. two"},
+		{"bold heading", "# **Title**\n## **a** and __b__ *c*", "*Title*\n*a and b _c_*", "<b>Title</b>\n<b>a and b <i>c</i></b>"},
+		{"quote", "> a.b\n> c", ">a\\.b\n>c", "<blockquote>a.b\nc</blockquote>"},
+		{"code block", "```go\nx := `a` < b\n```", "```go\nx := \\`a\\` < b\n```", `<pre><code class="language-go">x := ` + "`a`" + ` &lt; b</code></pre>`},
+		{"escapes", `\*not italic\*`, `\*not italic\*`, "*not italic*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := telegram.ToMarkdownV2(tt.markdown); got != tt.markdownV2 {
+				t.Errorf("ToMarkdownV2() = %q, want %q", got, tt.markdownV2)
+			}
+			if got := telegram.ToHTML(tt.markdown); got != tt.html {
+				t.Errorf("ToHTML() = %q, want %q", got, tt.html)
+			}
+		})
+	}
+
+	server := parrottest.NewTelegramServer()
+	defer server.Close()
+	client, err := telegram.NewClient(server.Config(), nil)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	// Markdown is converted, unless the caller picked a parse mode
+	msgs := []*types.Message{
+		{Type: types.MessageTypeMarkdown, Content: "**done** v1.2"},
+		{Type: types.MessageTypeMarkdown, Content: "<b>done</b>", Data: map[string]interface{}{"parse_mode": "HTML"}},
+	}
+	for _, msg := range msgs {
+		if err := client.SendPrivateMessage(context.Background(), "1", msg); err != nil {
+			t.Fatalf("Failed to send markdown: %v", err)
+		}
+	}
+	reqs := server.RequestsTo("/sendMessage")
+	if !bytes.Contains(reqs[0].Body, []byte(`"text":"*done* v1\\.2"`)) {
+		t.Errorf("Expected converted MarkdownV2, got %s", reqs[0].Body)
+	}
+	if !bytes.Contains(reqs[1].Body, []byte(`"parse_mode":"HTML"`)) || !bytes.Contains(reqs[1].Body, []byte(`"text":"\u003cb\u003edone\u003c/b\u003e"`)) {
+		t.Errorf("Expected raw HTML content, got %s", reqs[1].Body)
+	}
+}
+
+// TestSplitMessages tests that oversize messages are split when asked to
+func TestSplitMessages(t *testing.T) {
+	server := parrottest.NewTelegramServer()
+	defer server.Close()
+
+	client, err := imparrot.NewIMClient(imparrot.PlatformTelegram, server.Config())
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	paragraph := strings.Repeat("磁盘空间不足 ", 300)
+	msg := &types.Message{Type: types.MessageTypeMarkdown, Content: paragraph + "\n\n" + paragraph}
+	targets := []types.Target{{ID: "1"}, {ID: "2"}}
+
+	results, err := client.SendMessageWithResult(context.Background(), msg, &types.SendOptions{Targets: targets, Split: true})
+	if err != nil {
+		t.Fatalf("SendMessageWithResult() error = %v", err)
+	}
+	if len(results) != 2 || len(results[0].Parts) != 2 || results[0].MessageID != results[0].Parts[0].MessageID {
+		t.Fatalf("Expected one result with 2 parts per target, got %+v", results)
+	}
+	if n := len(server.RequestsTo("/sendMessage")); n != 4 {
+		t.Errorf("Expected 4 sendMessage requests, got %d", n)
+	}
+
+	// Without Split the message is forwarded as is
+	server.Reset()
+	results, err = client.SendMessageWithResult(context.Background(), msg, &types.SendOptions{Targets: targets[:1]})
+	if err != nil || len(results) != 1 || results[0].Parts != nil {
+		t.Errorf("Expected an unsplit send, got %+v, %v", results, err)
+	}
+
+	// Lark webhook robots take request bodies up to 20 KB, lower than the app API
+	larkServer := parrottest.NewLarkServer()
+	defer larkServer.Close()
+	larkClient, err := lark.NewClient(larkServer.WebhookConfig(), nil)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer larkClient.Close()
+	long := &types.Message{Type: types.MessageTypeText, Content: strings.Repeat(paragraph+"\n\n", 4)}
+	results, err = larkClient.SendMessageWithResult(context.Background(), long, &types.SendOptions{Split: true})
+	if err != nil {
+		t.Fatalf("SendMessageWithResult() error = %v", err)
+	}
+	webhookRequests := larkServer.RequestsTo(parrottest.LarkWebhookPath)
+	if len(webhookRequests) < 2 || len(results[0].Parts) != len(webhookRequests) {
+		t.Fatalf("Expected the text to be split, got %d requests", len(webhookRequests))
+	}
+	for _, req := range webhookRequests {
+		if len(req.Body) > 20<<10 {
+			t.Errorf("Webhook request body is %d bytes, over 20 KB", len(req.Body))
+		}
+	}
+
+	// WPS has no documented limit to split at
+	wpsServer := parrottest.NewWPSServer()
+	defer wpsServer.Close()
+	wps, err := wpsxz.NewClient(wpsServer.Config(), nil)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer wps.Close()
+	_, err = wps.SendMessageWithResult(context.Background(), msg, &types.SendOptions{Targets: targets, Split: true})
+	if !errors.Is(err, types.ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported from wpsxz with Split, got %v", err)
+	}
+}
+
+// TestDingTalkCard tests that link, actionCard and feedCard payloads are sent as built
+func TestDingTalkCard(t *testing.T) {
+	server := parrottest.NewDingTalkServer()
+	defer server.Close()
+
+	client, err := dingtalk.NewClient(server.Config(), nil)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	builders := map[string]interface {
+		Message() (*types.Message, error)
+	}{
+		"link":       &dingtalk.Link{Title: "v1.2.0", Text: "发布完成", MessageURL: "https://ci.example.com/1"},
+		"actionCard": dingtalk.NewActionCard("v1.2.0", "### 发布完成").AddButton("View pipeline", "https://ci.example.com/1").AddButton("Rollback", "https://ci.example.com/1/rollback").Vertical(),
+		"feedCard":   dingtalk.NewFeedCard().Add("api", "https://ci.example.com/1", "").Add("web", "https://ci.example.com/2", ""),
+	}
+	for msgType, builder := range builders {
+		msg, err := builder.Message()
+		if err != nil {
+			t.Fatalf("%s: Message() error = %v", msgType, err)
+		}
+		if msg.Type != types.MessageTypeCard {
+			t.Errorf("%s: expected a card message, got %s", msgType, msg.Type)
+		}
+
+		server.Reset()
+		if err := client.SendGroupMessage(context.Background(), "", msg); err != nil {
+			t.Fatalf("%s: SendGroupMessage() error = %v", msgType, err)
+		}
+		requests := server.RequestsTo("/robot/send")
+		if len(requests) != 1 || !strings.Contains(string(requests[0].Body), `"msgtype":"`+msgType+`"`) {
+			t.Errorf("%s: unexpected requests %v", msgType, requests)
+		}
+	}
+
+	// DingTalk's btnOrientation is "0" for vertical and "1" for horizontal buttons
+	for want, card := range map[string]*dingtalk.ActionCard{
+		"0": dingtalk.NewActionCard("v1.2.0", "x").AddButton("A", "https://a").AddButton("B", "https://b").Vertical(),
+		"1": dingtalk.NewActionCard("v1.2.0", "x").AddButton("A", "https://a").AddButton("B", "https://b"),
+	} {
+		data, err := card.JSON()
+		if err != nil {
+			t.Fatalf("JSON() error = %v", err)
+		}
+		if !strings.Contains(string(data), `"btnOrientation":"`+want+`"`) {
+			t.Errorf("Expected btnOrientation %s, got %s", want, data)
+		}
+	}
+
+	invalid := []interface{ Validate() error }{
+		&dingtalk.Link{Title: "no url", Text: "x"},
+		dingtalk.NewActionCard("no buttons", "x"),
+		dingtalk.NewActionCard("both", "x").SingleButton("Read", "https://a").AddButton("Go", "https://b"),
+		dingtalk.NewFeedCard(),
+	}
+	for i, v := range invalid {
+		if err := v.Validate(); err == nil {
+			t.Errorf("Expected payload %d to be invalid", i)
+		}
+	}
+
+	// Card content must be a complete robot payload
+	err = client.SendGroupMessage(context.Background(), "", &types.Message{Type: types.MessageTypeCard, Content: `{"title":"x"}`})
+	if err == nil {
+		t.Error("Expected an error for a card without msgtype")
+	}
+}
+
+// TestDingTalkMentions tests mobile, user ID and @all mentions and their validation
+func TestDingTalkMentions(t *testing.T) {
+	server := parrottest.NewDingTalkServer()
+	defer server.Close()
+
+	client, err := dingtalk.NewClient(server.Config(), nil)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	markdown := &types.Message{Type: types.MessageTypeMarkdown, Content: "### 发布失败\n\n请 @13800000000 处理"}
+	opts := &types.SendOptions{
+		AtUsers: []string{"13800000000"},
+		Extra:   map[string]interface{}{dingtalk.MentionsKey: dingtalk.Mentions{UserIDs: []string{"manager01"}}},
+	}
+	if err := client.SendMessage(context.Background(), markdown, opts); err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+
+	var body struct {
+		Markdown struct {
+			Text string `json:"text"`
+		} `json:"markdown"`
+		At struct {
+			AtMobiles []string `json:"atMobiles"`
+			AtUserIds []string `json:"atUserIds"`
+			IsAtAll   bool     `json:"isAtAll"`
+		} `json:"at"`
+	}
+	requests := server.RequestsTo("/robot/send")
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 request, got %d", len(requests))
+	}
+	if err := json.Unmarshal(requests[0].Body, &body); err != nil {
+		t.Fatalf("Failed to decode request: %v", err)
+	}
+	if len(body.At.AtMobiles) != 1 || len(body.At.AtUserIds) != 1 || body.At.IsAtAll {
+		t.Errorf("Unexpected at block: %+v", body.At)
+	}
+	// The mobile is already in the text, only the user ID is appended
+	if strings.Count(body.Markdown.Text, "@13800000000") != 1 || !strings.HasSuffix(body.Markdown.Text, "\n\n@manager01") {
+		t.Errorf("Unexpected markdown text: %q", body.Markdown.Text)
+	}
+
+	all := &types.SendOptions{Extra: map[string]interface{}{dingtalk.MentionsKey: &dingtalk.Mentions{All: true}}}
+	if err := client.SendMessage(context.Background(), &types.Message{Type: types.MessageTypeText, Content: "全员注意"}, all); err != nil {
+		t.Errorf("SendMessage() with @all error = %v", err)
+	}
+
+	card, _ := dingtalk.NewActionCard("x", "y").SingleButton("Go", "https://example.com").Message()
+	invalid := []struct {
+		msg  *types.Message
+		opts *types.SendOptions
+	}{
+		{markdown, &types.SendOptions{AtUsers: []string{"13800000000"}, Extra: all.Extra}},
+		{markdown, &types.SendOptions{Extra: map[string]interface{}{dingtalk.MentionsKey: dingtalk.Mentions{UserIDs: []string{""}}}}},
+		{markdown, &types.SendOptions{Extra: map[string]interface{}{dingtalk.MentionsKey: []string{"manager01"}}}},
+		{card, &types.SendOptions{AtUsers: []string{"13800000000"}}},
+	}
+	server.Reset()
+	for i, tt := range invalid {
+		if err := client.SendMessage(context.Background(), tt.msg, tt.opts); err == nil {
+			t.Errorf("Expected mentions %d to be rejected", i)
+		}
+	}
+	if n := len(server.Requests()); n != 0 {
+		t.Errorf("Expected invalid mentions not to be sent, got %d requests", n)
+	}
+}
+
+// TestDingTalkApp tests private and group messages sent as an enterprise internal app
+func TestDingTalkApp(t *testing.T) {
+	server := parrottest.NewDingTalkServer()
+	defer server.Close()
+
+	client, err := imparrot.NewIMClient(imparrot.PlatformDingTalk, server.AppConfig())
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	msg := &types.Message{Type: types.MessageTypeMarkdown, Content: "### 发布完成"}
+	if err := client.SendPrivateMessage(context.Background(), "manager01", msg); err != nil {
+		t.Fatalf("SendPrivateMessage() error = %v", err)
+	}
+
+	// btnOrientation "1" lays the 2 buttons out horizontally
+	card := &types.Message{Type: types.MessageTypeCard, Content: `{"msgtype":"actionCard","actionCard":{"title":"v1.2.0","text":"发布完成",` +
+		`"btnOrientation":"1","btns":[{"title":"View pipeline","actionURL":"https://ci.example.com/1"},{"title":"Rollback","actionURL":"https://ci.example.com/1/rollback"}]}}`}
+	results, err := client.SendMessageWithResult(context.Background(), card, &types.SendOptions{
+		Targets: []types.Target{{ID: "cid123", ChatType: types.ChatTypeGroup}},
+	})
+	if err != nil {
+		t.Fatalf("SendMessageWithResult() error = %v", err)
+	}
+	if len(results) != 1 || !strings.HasPrefix(results[0].MessageID, "query_") || results[0].ChatID != "cid123" {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+
+	var batch, group struct {
+		RobotCode          string   `json:"robotCode"`
+		UserIDs            []string `json:"userIds"`
+		OpenConversationID string   `json:"openConversationId"`
+		MsgKey             string   `json:"msgKey"`
+		MsgParam           string   `json:"msgParam"`
+	}
+	batchRequests := server.RequestsTo("/v1.0/robot/oToMessages/batchSend")
+	groupRequests := server.RequestsTo("/v1.0/robot/groupMessages/send")
+	if len(batchRequests) != 1 || len(groupRequests) != 1 {
+		t.Fatalf("Expected one batchSend and one group send, got %d and %d", len(batchRequests), len(groupRequests))
+	}
+	_ = json.Unmarshal(batchRequests[0].Body, &batch)
+	_ = json.Unmarshal(groupRequests[0].Body, &group)
+	if batch.RobotCode != parrottest.DingTalkAppKey || len(batch.UserIDs) != 1 || batch.MsgKey != "sampleMarkdown" {
+		t.Errorf("Unexpected batchSend request: %+v", batch)
+	}
+	if group.OpenConversationID != "cid123" || group.MsgKey != "sampleActionCard6" ||
+		!strings.Contains(group.MsgParam, `"buttonTitle2":"Rollback"`) || !strings.Contains(group.MsgParam, `"buttonUrl1":"https://ci.example.com/1"`) {
+		t.Errorf("Unexpected group send request: %+v", group)
+	}
+
+	// Vertical buttons use sampleActionCard2-5
+	server.Reset()
+	card.Content = strings.Replace(card.Content, `"btnOrientation":"1"`, `"btnOrientation":"0"`, 1)
+	if err := client.SendGroupMessage(context.Background(), "cid123", card); err != nil {
+		t.Fatalf("SendGroupMessage() error = %v", err)
+	}
+	_ = json.Unmarshal(server.RequestsTo("/v1.0/robot/groupMessages/send")[0].Body, &group)
+	if group.MsgKey != "sampleActionCard2" || !strings.Contains(group.MsgParam, `"actionTitle2":"Rollback"`) {
+		t.Errorf("Unexpected group send request: %+v", group)
+	}
+
+	// Messages sent by the app robot can be recalled with their receipt
+	server.Reset()
+	editor := client.(imparrot.MessageEditor)
+	if err := editor.RecallMessage(context.Background(), &results[0]); err != nil {
+		t.Errorf("RecallMessage() error = %v", err)
+	}
+	recalls := server.RequestsTo("/v1.0/robot/groupMessages/recall")
+	if len(recalls) != 1 || !strings.Contains(string(recalls[0].Body), `"processQueryKeys":["`+results[0].MessageID+`"]`) ||
+		!strings.Contains(string(recalls[0].Body), `"openConversationId":"cid123"`) {
+		t.Errorf("Unexpected recall requests: %v", recalls)
+	}
+	private := &types.SendResult{Target: types.Target{ID: "manager01", ChatType: types.ChatTypePrivate}, MessageID: "query_1", ChatID: "manager01"}
+	if err := editor.RecallMessage(context.Background(), private); err != nil || len(server.RequestsTo("/v1.0/robot/otoMessages/batchRecall")) != 1 {
+		t.Errorf("Expected a batchRecall request, got %v", err)
+	}
+	unknown := &types.SendResult{Target: types.Target{ID: "cid123", ChatType: types.ChatTypeGroup}, MessageID: "unknown", ChatID: "cid123"}
+	if _, ok := types.AsAPIError(editor.RecallMessage(context.Background(), unknown)); !ok {
+		t.Error("Expected a failed recall to return an APIError")
+	}
+	if err := editor.UpdateMessage(context.Background(), &results[0], msg); !errors.Is(err, types.ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported from UpdateMessage, got %v", err)
+	}
+
+	// Feed cards and mentions are only supported by webhook robots
+	feed, _ := dingtalk.NewFeedCard().Add("api", "https://ci.example.com/1", "").Message()
+	if err := client.SendGroupMessage(context.Background(), "cid123", feed); err == nil {
+		t.Error("Expected a feed card to be rejected in app mode")
+	}
+	err = client.SendMessage(context.Background(), msg, &types.SendOptions{
+		Targets: []types.Target{{ID: "cid123", ChatType: types.ChatTypeGroup}},
+		AtUsers: []string{"13800000000"},
+	})
+	if err == nil {
+		t.Error("Expected mentions to be rejected in app mode")
+	}
+
+	config := server.AppConfig()
+	config.AppSecret = "wrong"
+	if _, err := dingtalk.NewClient(config, nil); err == nil {
+		t.Error("Expected an invalid AppSecret to fail")
+	}
+}
+
+// TestDingTalkOutgoing tests signature and timestamp checks of outgoing robot
+// callbacks and replies through the session webhook
+func TestDingTalkOutgoing(t *testing.T) {
+	server := parrottest.NewDingTalkServer()
+	defer server.Close()
+
+	const secret = "app-secret"
+	sign := func(ts time.Time) (string, string) {
+		timestamp := strconv.FormatInt(ts.UnixMilli(), 10)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(timestamp + "\n" + secret))
+		return timestamp, base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	var received *dingtalk.OutgoingMessage
+	reply := func(ctx context.Context, msg *dingtalk.OutgoingMessage) (*types.Message, error) {
+		received = msg
+		return &types.Message{Type: types.MessageTypeText, Content: "收到: " + strings.TrimSpace(msg.Text.Content)}, nil
+	}
+	if _, err := dingtalk.NewOutgoingHandler("", nil, reply); err == nil {
+		t.Error("Expected an empty secret to be rejected")
+	}
+	handler, err := dingtalk.NewOutgoingHandler(secret, server.Client(), reply)
+	if err != nil {
+		t.Fatalf("NewOutgoingHandler() error = %v", err)
+	}
+
+	payload := `{"msgtype":"text","text":{"content":" 部署 api "},"msgId":"msg1","conversationType":"2",` +
+		`"conversationId":"cid1","conversationTitle":"发布群","senderNick":"Ann","senderStaffId":"user01",` +
+		`"chatbotUserId":"bot1","isInAtList":true,"atUsers":[{"dingtalkId":"bot1"},{"dingtalkId":"u2","staffId":"user02"}],` +
+		`"sessionWebhook":"` + server.SessionWebhookURL() + `","sessionWebhookExpiredTime":` +
+		strconv.FormatInt(time.Now().Add(time.Hour).UnixMilli(), 10) + `}`
+
+	post := func(ts time.Time) int {
+		timestamp, signature := sign(ts)
+		req := httptest.NewRequest(http.MethodPost, "/dingtalk", strings.NewReader(payload))
+		req.Header.Set("timestamp", timestamp)
+		req.Header.Set("sign", signature)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := post(time.Now()); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if received == nil || !received.IsGroup() || received.SenderStaffID != "user01" || len(received.AtUsers) != 2 {
+		t.Errorf("Unexpected message: %+v", received)
+	}
+	requests := server.RequestsTo("/robot/sendBySession")
+	if len(requests) != 1 || !strings.Contains(string(requests[0].Body), "收到: 部署 api") {
+		t.Errorf("Expected a reply through the session webhook, got %v", requests)
+	}
+
+	received = nil
+	if code := post(time.Now().Add(-2 * time.Hour)); code != http.StatusUnauthorized || received != nil {
+		t.Errorf("Expected a stale timestamp to be rejected with 401, got %d", code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/dingtalk", strings.NewReader(payload))
+	req.Header.Set("timestamp", strconv.FormatInt(time.Now().UnixMilli(), 10))
+	req.Header.Set("sign", "forged")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a forged signature to be rejected with 401, got %d", rec.Code)
+	}
+
+	expired := &dingtalk.OutgoingMessage{SessionWebhook: server.SessionWebhookURL(), SessionWebhookExpiredTime: time.Now().Add(-time.Minute).UnixMilli()}
+	if err := expired.Reply(context.Background(), nil, &types.Message{Type: types.MessageTypeText, Content: "late"}, nil); err == nil {
+		t.Error("Expected a reply to an expired session webhook to fail")
+	}
+}
+
+// TestWeChatTargets tests app group chats and the user, department, tag and
+// group targets of WeChat Work
+func TestWeChatTargets(t *testing.T) {
+	server := parrottest.NewWeChatServer()
+	defer server.Close()
+
+	client, err := wechat.NewClient(server.Config(), nil)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	chatID, err := client.CreateAppChat(ctx, &wechat.AppChat{Name: "发布群", UserList: []string{"zhangsan", "lisi"}})
+	if err != nil {
+		t.Fatalf("CreateAppChat() error = %v", err)
+	}
+	if err := client.UpdateAppChat(ctx, chatID, &wechat.AppChatUpdate{Name: "发布通知", AddUsers: []string{"wangwu"}, RemoveUsers: []string{"lisi"}}); err != nil {
+		t.Fatalf("UpdateAppChat() error = %v", err)
+	}
+	chat, err := client.GetAppChat(ctx, chatID)
+	if err != nil {
+		t.Fatalf("GetAppChat() error = %v", err)
+	}
+	if chat.ChatID != chatID || chat.Name != "发布通知" || strings.Join(chat.UserList, ",") != "zhangsan,wangwu" {
+		t.Errorf("Unexpected chat: %+v", chat)
+	}
+
+	msg := &types.Message{Type: types.MessageTypeText, Content: "发布完成"}
+	targets := []types.Target{
+		{ID: wechat.AllUsers, ChatType: types.ChatTypePrivate},
+		{ID: "2", ChatType: wechat.ChatTypeDepartment},
+		{ID: "3", ChatType: wechat.ChatTypeTag},
+		{ID: "4", ChatType: types.ChatTypeGroup},
+		{ID: chatID, ChatType: wechat.ChatTypeAppChat},
+	}
+	if err := client.SendMessage(ctx, msg, &types.SendOptions{Targets: targets}); err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+
+	sends := server.RequestsTo("/cgi-bin/message/send")
+	if len(sends) != 4 {
+		t.Fatalf("Expected 4 message/send requests, got %d", len(sends))
+	}
+	for i, want := range []string{`"touser":"@all"`, `"toparty":"2"`, `"totag":"3"`, `"toparty":"4"`} {
+		if !strings.Contains(string(sends[i].Body), want) {
+			t.Errorf("Expected %s in request %d, got %s", want, i, sends[i].Body)
+		}
+	}
+	if n := len(server.RequestsTo("/cgi-bin/appchat/send")); n != 1 {
+		t.Errorf("Expected 1 appchat/send request, got %d", n)
+	}
+
+	// With AppChatGroups, group targets are app group chats
+	config := server.Config()
+	config.AppChatGroups = true
+	appChatClient, err := wechat.NewClient(config, nil)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer appChatClient.Close()
+
+	server.Reset()
+	if err := appChatClient.SendGroupMessage(ctx, chatID, msg); err != nil {
+		t.Fatalf("SendGroupMessage() error = %v", err)
+	}
+	requests := server.RequestsTo("/cgi-bin/appchat/send")
+	if len(requests) != 1 || !strings.Contains(string(requests[0].Body), `"chatid":"`+chatID+`"`) || len(server.RequestsTo("/cgi-bin/message/send")) != 0 {
+		t.Errorf("Expected the group message to be sent with appchat/send, got %v", server.Requests())
+	}
+}
+
+// TestWPSXZ tests the token exchange and caching, the message request and error
+// classification of WPS Xiezuo
+func TestWPSXZ(t *testing.T) {
+	server := parrottest.NewWPSServer()
+	defer server.Close()
+
+	client, err := imparrot.NewIMClient(imparrot.PlatformWPSXZ, server.Config())
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	defer client.Close()
+
+	tokenRequests := server.RequestsTo("/oauth2/token")
+	if len(tokenRequests) != 1 {
+		t.Fatalf("Expected 1 token request, got %d", len(tokenRequests))
+	}
+	form, _ := url.ParseQuery(string(tokenRequests[0].Body))
+	if form.Get("grant_type") != "client_credentials" || form.Get("client_id") != parrottest.WPSAppID || form.Get("client_secret") != parrottest.WPSAppSecret {
+		t.Errorf("Unexpected token request: %s", tokenRequests[0].Body)
+	}
+
+	results, err := client.SendMessageWithResult(context.Background(), &types.Message{Type: types.MessageTypeMarkdown, Content: "**发布完成**"}, &types.SendOptions{
+		Targets: []types.Target{{ID: "chat1", ChatType: types.ChatTypeGroup}, {ID: "user1", ChatType: types.ChatTypePrivate}},
+	})
+	if err != nil {
+		t.Fatalf("SendMessageWithResult() error = %v", err)
+	}
+	if len(results) != 2 || !strings.HasPrefix(results[0].MessageID, "msg_") {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+
+	// The token is cached across sends
+	sends := server.RequestsTo("/v7/messages/create")
+	if n := len(server.RequestsTo("/oauth2/token")); n != 1 || len(sends) != 2 {
+		t.Fatalf("Expected 1 token and 2 send requests, got %d and %d", n, len(sends))
+	}
+	var body struct {
+		Type     string `json:"type"`
+		Receiver struct {
+			Type        string   `json:"type"`
+			ReceiverIDs []string `json:"receiver_ids"`
+		} `json:"receiver"`
+		Content struct {
+			Text struct {
+				Content string `json:"content"`
+				Type    string `json:"type"`
+			} `json:"text"`
+		} `json:"content"`
+	}
+	receivers := map[string]string{}
+	for _, req := range sends {
+		if req.Method != http.MethodPost || req.Header.Get("Authorization") != "Bearer "+parrottest.WPSAccessToken {
+			t.Errorf("Unexpected send request: %s %v", req.Method, req.Header)
+		}
+		_ = json.Unmarshal(req.Body, &body)
+		if body.Type != "text" || body.Content.Text.Type != "markdown" || body.Content.Text.Content != "**发布完成**" || len(body.Receiver.ReceiverIDs) != 1 {
+			t.Errorf("Unexpected send body: %s", req.Body)
+		}
+		receivers[body.Receiver.ReceiverIDs[0]] = body.Receiver.Type
+	}
+	if receivers["chat1"] != "chat" || receivers["user1"] != "user" {
+		t.Errorf("Expected chat and user receivers, got %v", receivers)
+	}
+
+	// A rejected token is dropped and fetched again
+	server.Reset()
+	server.Inject(parrottest.Fault{Path: "/v7/messages/create", Status: http.StatusUnauthorized, Code: 401, Message: "token expired", Times: 1})
+	if err := client.SendGroupMessage(context.Background(), "chat1", &types.Message{Type: types.MessageTypeText, Content: "hi"}); err != nil {
+		t.Errorf("Expected the send to be retried with a new token, got %v", err)
+	}
+	if n := len(server.RequestsTo("/oauth2/token")); n != 1 {
+		t.Errorf("Expected the token to be refreshed once, got %d requests", n)
+	}
+
+	server.Inject(parrottest.Fault{Path: "/v7/messages/create", Status: http.StatusForbidden, Code: 403, Message: "no permission"})
+	err = client.SendGroupMessage(context.Background(), "chat1", &types.Message{Type: types.MessageTypeText, Content: "hi"})
+	var sendErr *types.SendError
+	if !errors.As(err, &sendErr) || len(sendErr.FailedTargets) != 1 {
+		t.Fatalf("Expected a SendError, got %v", err)
+	}
+	apiErr, ok := types.AsAPIError(sendErr.FailedTargets[0].Error)
+	if !ok || apiErr.Platform != "wpsxz" || apiErr.HTTPStatus != http.StatusForbidden || apiErr.Code != 403 || apiErr.Kind != types.ErrorKindAuth {
+		t.Errorf("Expected an auth APIError, got %v", err)
+	}
+}
+
+// TestLarkCard tests that built cards are sent in both app and webhook mode
+func TestLarkCard(t *testing.T) {
+	card := lark.NewCard().
+		SetHeader(lark.CardHeader{Title: "发布完成", Template: lark.TemplateGreen, I18n: map[string]string{"en_us": "Deployed"}}).
+		Locales("zh_cn", "en_us").
+		Add(
+			lark.Markdown("**api** v1.2.0").WithI18n("en_us", "**api** v1.2.0 is live"),
+			lark.Hr(),
+			lark.ColumnSet(lark.Column(1, lark.Div("left")), lark.Column(2, lark.Note("right"))),
+			lark.Button("Approve", lark.ButtonPrimary).WithCallback(map[string]interface{}{"action": "approve"}),
+			lark.Select("Env", lark.SelectOption{Text: "Prod", Value: "prod"}).WithCallback(map[string]interface{}{"action": "env"}),
+			lark.DatePicker("Date"),
+		)
+
+	msg, err := card.Message()
+	if err != nil {
+		t.Fatalf("Message() error = %v", err)
+	}
+	if msg.Type != types.MessageTypeCard || !strings.Contains(msg.Content, `"schema":"2.0"`) {
+		t.Errorf("Unexpected card message: %+v", msg)
+	}
+
+	server := parrottest.NewLarkServer()
+	defer server.Close()
+
+	for _, config := range []*lark.Config{server.Config(), server.WebhookConfig()} {
+		client, err := lark.NewClient(config, nil)
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		if err := client.SendGroupMessage(context.Background(), "oc_1", msg); err != nil {
+			t.Errorf("SendGroupMessage() error = %v", err)
+		}
+	}
+	for _, path := range []string{"/im/v1/messages", parrottest.LarkWebhookPath} {
+		requests := server.RequestsTo(path)
+		if len(requests) != 1 || !strings.Contains(string(requests[0].Body), "interactive") {
+			t.Errorf("Expected an interactive card sent to %s, got %v", path, requests)
+		}
+	}
+
+	invalid := []*lark.Card{
+		lark.NewCard(),
+		lark.NewCard().Add(lark.Button("Go", lark.ButtonDefault)),
+		lark.NewCard().Add(lark.Select("Pick")),
+		lark.NewCard().SetHeader(lark.CardHeader{Title: "x", Template: "pink"}).Add(lark.Hr()),
+	}
+	for i, c := range invalid {
+		if err := c.Validate(); err == nil {
+			t.Errorf("Expected card %d to be invalid", i)
+		}
+	}
+
+	tooLarge := lark.NewCard()
+	for i := 0; i < 201; i++ {
+		tooLarge.Add(lark.Hr())
+	}
+	if err := tooLarge.Validate(); err == nil {
+		t.Error("Expected a card with 201 components to be invalid")
+	}
+}
+
+// TestLarkCardAction tests decrypting a card callback and answering with a toast and card
+func TestLarkCardAction(t *testing.T) {
+	var action *lark.CardAction
+	handler := lark.NewCardActionHandler("verify", "encrypt-key", func(ctx context.Context, a *lark.CardAction) (*lark.CardActionResponse, error) {
+		action = a
+		return &lark.CardActionResponse{
+			Toast: &lark.Toast{Type: lark.ToastSuccess, Content: "Approved"},
+			Card:  lark.NewCard().Add(lark.Markdown("Approved by <at id=" + a.OperatorOpenID + "></at>")),
+		}, nil
+	})
+
+	event := `{"schema":"2.0","header":{"event_type":"card.action.trigger","token":"verify"},"event":{` +
+		`"operator":{"open_id":"ou_1"},"token":"c-1",` +
+		`"action":{"tag":"button","value":{"action":"approve"},"form_value":{"reason":"ok"}},` +
+		`"context":{"open_message_id":"om_1","open_chat_id":"oc_1"}}}`
+	body, _ := json.Marshal(map[string]string{"encrypt": larkEncrypt(t, "encrypt-key", event)})
+
+	post := func(body []byte, signature string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/card", bytes.NewReader(body))
+		req.Header.Set("X-Lark-Request-Timestamp", "1700000000")
+		req.Header.Set("X-Lark-Request-Nonce", "nonce")
+		req.Header.Set("X-Lark-Signature", signature)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	sum := sha256.Sum256([]byte("1700000000" + "nonce" + "encrypt-key" + string(body)))
+
+	if rec := post(body, "forged"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a forged signature to be rejected, got %d", rec.Code)
+	}
+	rec := post(body, hex.EncodeToString(sum[:]))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if action == nil || action.Value["action"] != "approve" || action.OperatorOpenID != "ou_1" ||
+		action.MessageID != "om_1" || action.FormValue["reason"] != "ok" {
+		t.Fatalf("Unexpected action: %+v", action)
+	}
+
+	var resp struct {
+		Toast struct {
+			Type    string `json:"type"`
+			Content string `json:"content"`
+		} `json:"toast"`
+		Card struct {
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		} `json:"card"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	if resp.Toast.Type != "success" || resp.Card.Type != "raw" || !strings.Contains(string(resp.Card.Data), "Approved by") {
+		t.Errorf("Unexpected response: %s", rec.Body.String())
+	}
+
+	// Legacy callbacks are signed with the verification token and answered with the raw card
+	legacy := []byte(`{"open_id":"ou_2","open_message_id":"om_2","token":"c-2","action":{"tag":"button","value":{"action":"approve"}}}`)
+	legacySum := sha1.Sum([]byte("1700000000" + "nonce" + "verify" + string(legacy)))
+	if rec := post(legacy, hex.EncodeToString(sum[:])); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected a wrong legacy signature to be rejected, got %d", rec.Code)
+	}
+	rec = post(legacy, hex.EncodeToString(legacySum[:]))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if action.OperatorOpenID != "ou_2" || action.Token != "c-2" {
+		t.Errorf("Unexpected legacy action: %+v", action)
+	}
+	var card map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &card); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	if _, wrapped := card["card"]; wrapped || card["toast"] != nil || !strings.Contains(rec.Body.String(), "Approved by") {
+		t.Errorf("Expected the raw card, got %s", rec.Body.String())
+	}
+}
+
+// TestLarkEventDispatcher tests signature checks, decryption, dedup and typed dispatch
+func TestLarkEventDispatcher(t *testing.T) {
+	const encryptKey = "encrypt-key"
+
+	var botAdded []string
+	failures := 1
+	dispatcher := lark.NewEventDispatcher(&lark.Config{VerificationToken: "verify", EncryptKey: encryptKey}).
+		OnBotAdded(func(ctx context.Context, event *lark.ChatBotEvent) error {
+			if failures > 0 {
+				failures--
+				return errors.New("temporary failure")
+			}
+			botAdded = append(botAdded, event.ChatID)
+			return nil
+		})
+
+	post := func(plaintext string, sign bool) int {
+		body, _ := json.Marshal(map[string]string{"encrypt": larkEncrypt(t, encryptKey, plaintext)})
+		req := httptest.NewRequest(http.MethodPost, "/events", bytes.NewReader(body))
+		if sign {
+			sum := sha256.Sum256([]byte("1700000000" + "nonce" + encryptKey + string(body)))
+			req.Header.Set("X-Lark-Request-Timestamp", "1700000000")
+			req.Header.Set("X-Lark-Request-Nonce", "nonce")
+			req.Header.Set("X-Lark-Signature", hex.EncodeToString(sum[:]))
+		}
+		rec := httptest.NewRecorder()
+		dispatcher.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := post(`{"type":"url_verification","token":"verify","challenge":"c"}`, false); code != http.StatusOK {
+		t.Errorf("Expected challenge to succeed, got %d", code)
+	}
+
+	event := `{"schema":"2.0","header":{"event_id":"ev_1","event_type":"im.chat.member.bot.added_v1","token":"verify"},` +
+		`"event":{"chat_id":"oc_1","operator_id":{"open_id":"ou_1"},"name":"ops"}}`
+	if code := post(event, false); code != http.StatusUnauthorized {
+		t.Errorf("Expected unsigned event to be rejected, got %d", code)
+	}
+	if code := post(event, true); code != http.StatusInternalServerError {
+		t.Errorf("Expected handler failure to answer 500, got %d", code)
+	}
+	// Redelivery after the failure is handled, later duplicates are dropped
+	for i := 0; i < 2; i++ {
+		if code := post(event, true); code != http.StatusOK {
+			t.Errorf("Expected 200, got %d", code)
+		}
+	}
+	if len(botAdded) != 1 || botAdded[0] != "oc_1" {
+		t.Errorf("Expected a single bot added event for oc_1, got %v", botAdded)
+	}
+
+	// A redelivery while the handler is still running is refused, not acknowledged
+	started, release := make(chan struct{}), make(chan struct{})
+	dispatcher.On("test.slow", func(ctx context.Context, event *lark.Event) error {
+		close(started)
+		<-release
+		return nil
+	})
+	slow := `{"schema":"2.0","header":{"event_id":"ev_2","event_type":"test.slow","token":"verify"},"event":{}}`
+	first := make(chan int, 1)
+	go func() { first <- post(slow, true) }()
+	<-started
+	if code := post(slow, true); code != http.StatusConflict {
+		t.Errorf("Expected redelivery of a running event to answer 409, got %d", code)
+	}
+	close(release)
+	if code := <-first; code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", code)
+	}
+
+	// The dedup state is capped, the oldest events are forgotten first
+	handled := 0
+	capped := lark.NewEventDispatcher(&lark.Config{}).On("test.count", func(ctx context.Context, event *lark.Event) error {
+		handled++
+		return nil
+	})
+	deliver := func(id int) {
+		body := `{"schema":"2.0","header":{"event_id":"ev_` + strconv.Itoa(id) + `","event_type":"test.count"},"event":{}}`
+		capped.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/events", strings.NewReader(body)))
+	}
+	for id := 0; id <= 4096; id++ {
+		deliver(id)
+	}
+	deliver(4096)
+	deliver(0)
+	if handled != 4098 {
+		t.Errorf("Expected the newest event to stay deduplicated and the oldest to be handled again, got %d calls", handled)
+	}
+}
+
+// larkEncrypt encrypts a callback body like Lark does with the app's Encrypt Key
+func larkEncrypt(t *testing.T, key, plaintext string) string {
+	t.Helper()
+
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pad := aes.BlockSize - len(plaintext)%aes.BlockSize
+	data := append([]byte(plaintext), bytes.Repeat([]byte{byte(pad)}, pad)...)
+
+	buf := make([]byte, aes.BlockSize+len(data))
+	iv := buf[:aes.BlockSize]
+	if _, err := rand.Read(iv); err != nil {
+		t.Fatal(err)
+	}
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(buf[aes.BlockSize:], data)
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// TestIncomingMessages tests that inbound webhooks are normalized into IncomingMessage
+func TestIncomingMessages(t *testing.T) {
+	var received []*imparrot.IncomingMessage
+	collect := func(ctx context.Context, msg *imparrot.IncomingMessage) error {
+		received = append(received, msg)
+		return nil
+	}
+
+	tg := telegram.NewWebhookHandler("secret", telegram.HandleMessages(collect))
+	update := `{"update_id":1,"message":{"message_id":7,"from":{"id":42,"is_bot":false,"first_name":"Ann"},` +
+		`"chat":{"id":-100,"type":"group"},"date":1700000000,"text":"hi @parrot_bot",` +
+		`"entities":[{"type":"mention","offset":3,"length":11}]}}`
+
+	req := httptest.NewRequest(http.MethodPost, "/telegram", strings.NewReader(update))
+	rec := httptest.NewRecorder()
+	tg.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without secret token, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/telegram", strings.NewReader(update))
+	req.Header.Set("X-Telegram-Bot-Api-Secret-Token", "secret")
+	rec = httptest.NewRecorder()
+	tg.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+
+	lk := lark.NewMessageHandler("verify", "", collect)
+	req = httptest.NewRequest(http.MethodPost, "/lark", strings.NewReader(`{"type":"url_verification","token":"verify","challenge":"c1"}`))
+	rec = httptest.NewRecorder()
+	lk.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), `"challenge":"c1"`) {
+		t.Errorf("Expected challenge echo, got %s", rec.Body.String())
+	}
+
+	event := `{"schema":"2.0","header":{"event_type":"im.message.receive_v1","token":"verify"},"event":{` +
+		`"sender":{"sender_id":{"open_id":"ou_1"},"sender_type":"user"},` +
+		`"message":{"message_id":"om_1","chat_id":"oc_1","chat_type":"p2p","message_type":"text",` +
+		`"create_time":"1700000000000","content":"{\"text\":\"@_user_1 hello\"}",` +
+		`"mentions":[{"key":"@_user_1","id":{"open_id":"ou_2"},"name":"Bob"}]}}}`
+	req = httptest.NewRequest(http.MethodPost, "/lark", strings.NewReader(event))
+	rec = httptest.NewRecorder()
+	lk.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+
+	if len(received) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(received))
+	}
+	if msg := received[0]; msg.ChatID != "-100" || msg.ChatType != imparrot.ChatTypeGroup ||
+		msg.Sender.Name != "Ann" || len(msg.Mentions) != 1 || msg.Mentions[0].Name != "parrot_bot" {
+		t.Errorf("Unexpected telegram message: %+v", msg)
+	}
+	if msg := received[1]; msg.Text != "@Bob hello" || msg.ChatType != imparrot.ChatTypePrivate ||
+		msg.Sender.ID != "ou_1" || len(msg.Mentions) != 1 || msg.Mentions[0].ID != "ou_2" {
+		t.Errorf("Unexpected lark message: %+v", msg)
+	}
+}
+
+// TestTelegramPoll tests long polling offsets and stopping on Close
+func TestTelegramPoll(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		offsets []float64
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		offset, _ := req["offset"].(float64)
+		mu.Lock()
+		offsets = append(offsets, offset)
+		mu.Unlock()
+
+		if offset == 0 {
+			_, _ = w.Write([]byte(`{"ok":true,"result":[` +
+				`{"update_id":10,"message":{"message_id":1,"chat":{"id":1,"type":"private"},"date":1,"text":"a"}},` +
+				`{"update_id":11,"message":{"message_id":2,"chat":{"id":1,"type":"private"},"date":2,"text":"b"}},` +
+				`{"update_id":12,"message":{"message_id":"malformed"}}]}`))
+			return
+		}
+		// Long poll until the client goes away
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client, err := telegram.NewClient(&telegram.Config{
+		BotToken:       "test-token",
+		BaseURL:        server.URL + "/bot",
+		AllowedUpdates: []string{"message"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	var texts []string
+	handler := telegram.HandleMessages(func(ctx context.Context, msg *imparrot.IncomingMessage) error {
+		texts = append(texts, msg.Text)
+		if len(texts) == 2 {
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				_ = client.Close()
+			}()
+		}
+		return nil
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- client.Poll(context.Background(), handler) }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Poll() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Poll did not stop after Close")
+	}
+
+	if len(texts) != 2 || texts[0] != "a" || texts[1] != "b" {
+		t.Errorf("Unexpected messages: %v", texts)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	// The malformed update is skipped but confirmed
+	if len(offsets) < 2 || offsets[1] != 13 {
+		t.Errorf("Expected second getUpdates with offset 13, got %v", offsets)
+	}
+}
+
 // BenchmarkMessageCreation benchmarks message creation
 func BenchmarkMessageCreation(b *testing.B) {
 	for i := 0; i < b.N; i++ {
@@ -6,12 +6,17 @@ import (
 
 // Re-export types for convenience
 type (
-	MessageType = types.MessageType
-	ChatType    = types.ChatType
-	Message     = types.Message
-	SendOptions = types.SendOptions
-	IMParrot    = types.IMParrot
-	Config      = types.Config
+	MessageType   = types.MessageType
+	ChatType      = types.ChatType
+	Message       = types.Message
+	SendOptions   = types.SendOptions
+	SendResult    = types.SendResult
+	IMParrot      = types.IMParrot
+	MessageEditor = types.MessageEditor
+	Config        = types.Config
+
+	IncomingMessage = types.IncomingMessage
+	MessageHandler  = types.MessageHandler
 )
 
 // Re-export popular constants
@@ -0,0 +1,631 @@
+package lark
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+// Card JSON 2.0 limits
+// 参考: https://open.feishu.cn/document/feishu-cards/card-json-v2-structure
+const (
+	maxCardComponents = 200       // Components in a card, nested ones included
+	maxCardSize       = 30 * 1024 // Size of the card JSON in bytes
+)
+
+// CardTemplate is the color theme of a card header
+type CardTemplate string
+
+const (
+	TemplateDefault   CardTemplate = "default"
+	TemplateBlue      CardTemplate = "blue"
+	TemplateWathet    CardTemplate = "wathet"
+	TemplateTurquoise CardTemplate = "turquoise"
+	TemplateGreen     CardTemplate = "green"
+	TemplateYellow    CardTemplate = "yellow"
+	TemplateOrange    CardTemplate = "orange"
+	TemplateRed       CardTemplate = "red"
+	TemplateCarmine   CardTemplate = "carmine"
+	TemplateViolet    CardTemplate = "violet"
+	TemplatePurple    CardTemplate = "purple"
+	TemplateIndigo    CardTemplate = "indigo"
+	TemplateGrey      CardTemplate = "grey"
+)
+
+// validTemplates lists the header templates accepted by Lark
+var validTemplates = map[CardTemplate]bool{
+	TemplateDefault: true, TemplateBlue: true, TemplateWathet: true, TemplateTurquoise: true,
+	TemplateGreen: true, TemplateYellow: true, TemplateOrange: true, TemplateRed: true,
+	TemplateCarmine: true, TemplateViolet: true, TemplatePurple: true, TemplateIndigo: true,
+	TemplateGrey: true,
+}
+
+// ButtonType is the style of a card button
+type ButtonType string
+
+const (
+	ButtonDefault ButtonType = "default"
+	ButtonPrimary ButtonType = "primary"
+	ButtonDanger  ButtonType = "danger"
+	ButtonText    ButtonType = "text"
+)
+
+// CardHeader is the title bar of a card
+type CardHeader struct {
+	Title    string
+	Subtitle string
+	Template CardTemplate      // Color theme (default: TemplateDefault)
+	I18n     map[string]string // Title per locale, e.g. {"en_us": "Deployed"}
+}
+
+// CardElement is a component of a card body
+type CardElement interface {
+	// toMap returns the Card JSON 2.0 representation of the component
+	toMap() map[string]interface{}
+	// validate checks the component and returns the number of components it contains
+	validate() (int, error)
+}
+
+// Card builds a Lark interactive card (Card JSON 2.0)
+// 参考: https://open.feishu.cn/document/feishu-cards/card-json-v2-structure
+//
+//	msg, err := lark.NewCard().
+//		SetHeader(lark.CardHeader{Title: "Deploy finished", Template: lark.TemplateGreen}).
+//		Add(
+//			lark.Markdown("**api** v1.2.0 is live"),
+//			lark.Button("Approve", lark.ButtonPrimary).WithCallback(map[string]interface{}{"action": "approve"}),
+//		).
+//		Message()
+type Card struct {
+	header   *CardHeader
+	elements []CardElement
+	locales  []string
+}
+
+// NewCard creates an empty card
+func NewCard() *Card {
+	return &Card{}
+}
+
+// SetHeader sets the card header
+func (c *Card) SetHeader(header CardHeader) *Card {
+	c.header = &header
+	return c
+}
+
+// Add appends components to the card body
+func (c *Card) Add(elements ...CardElement) *Card {
+	c.elements = append(c.elements, elements...)
+	return c
+}
+
+// Locales declares the locales the card is displayed in, e.g. "zh_cn", "en_us".
+// Texts fall back to their default content in locales without an i18n variant.
+func (c *Card) Locales(locales ...string) *Card {
+	c.locales = locales
+	return c
+}
+
+// Validate checks the card against the Card JSON 2.0 limits
+func (c *Card) Validate() error {
+	if c.header != nil && c.header.Template != "" && !validTemplates[c.header.Template] {
+		return fmt.Errorf("invalid header template %q", c.header.Template)
+	}
+
+	if len(c.elements) == 0 {
+		return fmt.Errorf("card must contain at least one element")
+	}
+
+	total, err := validateElements(c.elements)
+	if err != nil {
+		return err
+	}
+	if total > maxCardComponents {
+		return fmt.Errorf("card has %d components, at most %d are allowed", total, maxCardComponents)
+	}
+
+	return nil
+}
+
+// JSON validates the card and returns its JSON
+func (c *Card) JSON() ([]byte, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	config := map[string]interface{}{
+		"update_multi": true,
+	}
+	if len(c.locales) > 0 {
+		config["locales"] = c.locales
+	}
+
+	card := map[string]interface{}{
+		"schema": "2.0",
+		"config": config,
+		"body": map[string]interface{}{
+			"elements": elementMaps(c.elements),
+		},
+	}
+
+	if c.header != nil {
+		header := map[string]interface{}{
+			"title": plainText(c.header.Title, c.header.I18n),
+		}
+		if c.header.Subtitle != "" {
+			header["subtitle"] = plainText(c.header.Subtitle, nil)
+		}
+		if c.header.Template != "" {
+			header["template"] = string(c.header.Template)
+		}
+		card["header"] = header
+	}
+
+	data, err := json.Marshal(card)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxCardSize {
+		return nil, fmt.Errorf("card JSON is %d bytes, at most %d are allowed", len(data), maxCardSize)
+	}
+
+	return data, nil
+}
+
+// Message validates the card and wraps it in a MessageTypeCard message,
+// usable in both app mode and webhook mode
+func (c *Card) Message() (*types.Message, error) {
+	data, err := c.JSON()
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.Message{
+		Type:    types.MessageTypeCard,
+		Content: string(data),
+	}, nil
+}
+
+// validateElements validates elements and returns the number of components they contain
+func validateElements(elements []CardElement) (int, error) {
+	total := 0
+	for i, e := range elements {
+		if e == nil {
+			return 0, fmt.Errorf("element %d is nil", i)
+		}
+		n, err := e.validate()
+		if err != nil {
+			return 0, fmt.Errorf("element %d: %w", i, err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// elementMaps converts elements to their JSON representation
+func elementMaps(elements []CardElement) []map[string]interface{} {
+	maps := make([]map[string]interface{}, 0, len(elements))
+	for _, e := range elements {
+		maps = append(maps, e.toMap())
+	}
+	return maps
+}
+
+// plainText builds a plain_text object with optional i18n variants
+func plainText(content string, i18n map[string]string) map[string]interface{} {
+	text := map[string]interface{}{
+		"tag":     "plain_text",
+		"content": content,
+	}
+	if len(i18n) > 0 {
+		text["i18n_content"] = i18n
+	}
+	return text
+}
+
+// callbackBehaviors builds the behaviors of an interactive component
+func callbackBehaviors(url string, value map[string]interface{}) []map[string]interface{} {
+	behaviors := make([]map[string]interface{}, 0, 2)
+	if url != "" {
+		behaviors = append(behaviors, map[string]interface{}{
+			"type":        "open_url",
+			"default_url": url,
+		})
+	}
+	if value != nil {
+		behaviors = append(behaviors, map[string]interface{}{
+			"type":  "callback",
+			"value": value,
+		})
+	}
+	return behaviors
+}
+
+// MarkdownElement is a rich text component supporting Lark markdown
+type MarkdownElement struct {
+	Content   string
+	I18n      map[string]string // Content per locale
+	TextAlign string            // "left", "center" or "right"
+	TextSize  string            // e.g. "normal", "heading", "notation"
+}
+
+// Markdown creates a markdown component
+func Markdown(content string) *MarkdownElement {
+	return &MarkdownElement{Content: content}
+}
+
+// WithI18n sets the content shown in locale
+func (e *MarkdownElement) WithI18n(locale, content string) *MarkdownElement {
+	if e.I18n == nil {
+		e.I18n = make(map[string]string)
+	}
+	e.I18n[locale] = content
+	return e
+}
+
+func (e *MarkdownElement) toMap() map[string]interface{} {
+	m := map[string]interface{}{
+		"tag":     "markdown",
+		"content": e.Content,
+	}
+	if len(e.I18n) > 0 {
+		m["i18n_content"] = e.I18n
+	}
+	if e.TextAlign != "" {
+		m["text_align"] = e.TextAlign
+	}
+	if e.TextSize != "" {
+		m["text_size"] = e.TextSize
+	}
+	return m
+}
+
+func (e *MarkdownElement) validate() (int, error) {
+	if e.Content == "" && len(e.I18n) == 0 {
+		return 0, fmt.Errorf("markdown content is required")
+	}
+	return 1, nil
+}
+
+// Note creates a footnote in small grey text. Card JSON 2.0 has no note
+// component, so it is rendered as a markdown component in notation size.
+func Note(content string) *MarkdownElement {
+	return &MarkdownElement{Content: content, TextSize: "notation"}
+}
+
+// DivElement is a plain text component
+type DivElement struct {
+	Text string
+	I18n map[string]string // Text per locale
+}
+
+// Div creates a plain text component
+func Div(text string) *DivElement {
+	return &DivElement{Text: text}
+}
+
+// WithI18n sets the text shown in locale
+func (e *DivElement) WithI18n(locale, text string) *DivElement {
+	if e.I18n == nil {
+		e.I18n = make(map[string]string)
+	}
+	e.I18n[locale] = text
+	return e
+}
+
+func (e *DivElement) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"tag":  "div",
+		"text": plainText(e.Text, e.I18n),
+	}
+}
+
+func (e *DivElement) validate() (int, error) {
+	if e.Text == "" && len(e.I18n) == 0 {
+		return 0, fmt.Errorf("div text is required")
+	}
+	return 1, nil
+}
+
+// HrElement is a divider line
+type HrElement struct{}
+
+// Hr creates a divider line
+func Hr() *HrElement {
+	return &HrElement{}
+}
+
+func (e *HrElement) toMap() map[string]interface{} {
+	return map[string]interface{}{"tag": "hr"}
+}
+
+func (e *HrElement) validate() (int, error) {
+	return 1, nil
+}
+
+// ImageElement is an image uploaded to Lark
+type ImageElement struct {
+	ImgKey string // Key returned by the image upload API, e.g. "img_v2_xxx"
+	Alt    string // Text shown when hovering or when the image can't be loaded
+	Title  string
+}
+
+// Image creates an image component
+func Image(imgKey, alt string) *ImageElement {
+	return &ImageElement{ImgKey: imgKey, Alt: alt}
+}
+
+func (e *ImageElement) toMap() map[string]interface{} {
+	m := map[string]interface{}{
+		"tag":     "img",
+		"img_key": e.ImgKey,
+		"alt":     plainText(e.Alt, nil),
+	}
+	if e.Title != "" {
+		m["title"] = plainText(e.Title, nil)
+	}
+	return m
+}
+
+func (e *ImageElement) validate() (int, error) {
+	if e.ImgKey == "" {
+		return 0, fmt.Errorf("image img_key is required")
+	}
+	return 1, nil
+}
+
+// ColumnSetElement lays out columns side by side
+type ColumnSetElement struct {
+	Columns []*ColumnElement
+}
+
+// ColumnSet creates a column layout
+func ColumnSet(columns ...*ColumnElement) *ColumnSetElement {
+	return &ColumnSetElement{Columns: columns}
+}
+
+func (e *ColumnSetElement) toMap() map[string]interface{} {
+	columns := make([]map[string]interface{}, 0, len(e.Columns))
+	for _, col := range e.Columns {
+		columns = append(columns, col.toMap())
+	}
+	return map[string]interface{}{
+		"tag":       "column_set",
+		"flex_mode": "none",
+		"columns":   columns,
+	}
+}
+
+func (e *ColumnSetElement) validate() (int, error) {
+	if len(e.Columns) == 0 {
+		return 0, fmt.Errorf("column_set needs at least one column")
+	}
+
+	total := 1
+	for i, col := range e.Columns {
+		if col == nil {
+			return 0, fmt.Errorf("column %d is nil", i)
+		}
+		n, err := col.validate()
+		if err != nil {
+			return 0, fmt.Errorf("column %d: %w", i, err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// ColumnElement is a column of a ColumnSetElement
+type ColumnElement struct {
+	Weight   int // Relative width of the column (default: 1)
+	Elements []CardElement
+}
+
+// Column creates a column with the given relative width
+func Column(weight int, elements ...CardElement) *ColumnElement {
+	return &ColumnElement{Weight: weight, Elements: elements}
+}
+
+func (e *ColumnElement) toMap() map[string]interface{} {
+	weight := e.Weight
+	if weight < 1 {
+		weight = 1
+	}
+	return map[string]interface{}{
+		"tag":      "column",
+		"width":    "weighted",
+		"weight":   weight,
+		"elements": elementMaps(e.Elements),
+	}
+}
+
+func (e *ColumnElement) validate() (int, error) {
+	n, err := validateElements(e.Elements)
+	if err != nil {
+		return 0, err
+	}
+	return n + 1, nil
+}
+
+// ButtonElement is a button that opens a URL or sends a callback to the app
+type ButtonElement struct {
+	Text  string
+	I18n  map[string]string // Text per locale
+	Type  ButtonType
+	Name  string                 // Component name, reported in callbacks
+	URL   string                 // URL opened on click
+	Value map[string]interface{} // Value sent in the card action callback on click
+}
+
+// Button creates a button; set its action with WithURL or WithCallback
+func Button(text string, buttonType ButtonType) *ButtonElement {
+	return &ButtonElement{Text: text, Type: buttonType}
+}
+
+// WithURL makes the button open url
+func (e *ButtonElement) WithURL(url string) *ButtonElement {
+	e.URL = url
+	return e
+}
+
+// WithCallback makes the button send value to the card action callback
+func (e *ButtonElement) WithCallback(value map[string]interface{}) *ButtonElement {
+	e.Value = value
+	return e
+}
+
+// WithI18n sets the text shown in locale
+func (e *ButtonElement) WithI18n(locale, text string) *ButtonElement {
+	if e.I18n == nil {
+		e.I18n = make(map[string]string)
+	}
+	e.I18n[locale] = text
+	return e
+}
+
+func (e *ButtonElement) toMap() map[string]interface{} {
+	buttonType := e.Type
+	if buttonType == "" {
+		buttonType = ButtonDefault
+	}
+	m := map[string]interface{}{
+		"tag":       "button",
+		"text":      plainText(e.Text, e.I18n),
+		"type":      string(buttonType),
+		"behaviors": callbackBehaviors(e.URL, e.Value),
+	}
+	if e.Name != "" {
+		m["name"] = e.Name
+	}
+	return m
+}
+
+func (e *ButtonElement) validate() (int, error) {
+	if e.Text == "" && len(e.I18n) == 0 {
+		return 0, fmt.Errorf("button text is required")
+	}
+	if e.URL == "" && e.Value == nil {
+		return 0, fmt.Errorf("button %q needs a URL or a callback value", e.Text)
+	}
+	return 1, nil
+}
+
+// SelectOption is an option of a select menu
+type SelectOption struct {
+	Text  string
+	Value string
+}
+
+// SelectElement is a single choice drop-down menu
+type SelectElement struct {
+	Placeholder   string
+	Options       []SelectOption
+	InitialOption string                 // Value of the option selected initially
+	Name          string                 // Component name, reported in callbacks
+	Value         map[string]interface{} // Value sent in the card action callback with the chosen option
+}
+
+// Select creates a drop-down menu
+func Select(placeholder string, options ...SelectOption) *SelectElement {
+	return &SelectElement{Placeholder: placeholder, Options: options}
+}
+
+// WithCallback sends value to the card action callback when an option is chosen
+func (e *SelectElement) WithCallback(value map[string]interface{}) *SelectElement {
+	e.Value = value
+	return e
+}
+
+func (e *SelectElement) toMap() map[string]interface{} {
+	options := make([]map[string]interface{}, 0, len(e.Options))
+	for _, o := range e.Options {
+		options = append(options, map[string]interface{}{
+			"text":  plainText(o.Text, nil),
+			"value": o.Value,
+		})
+	}
+
+	m := map[string]interface{}{
+		"tag":         "select_static",
+		"placeholder": plainText(e.Placeholder, nil),
+		"options":     options,
+	}
+	if e.InitialOption != "" {
+		m["initial_option"] = e.InitialOption
+	}
+	if e.Name != "" {
+		m["name"] = e.Name
+	}
+	if e.Value != nil {
+		m["behaviors"] = callbackBehaviors("", e.Value)
+	}
+	return m
+}
+
+func (e *SelectElement) validate() (int, error) {
+	if len(e.Options) == 0 {
+		return 0, fmt.Errorf("select needs at least one option")
+	}
+
+	seen := make(map[string]bool, len(e.Options))
+	for _, o := range e.Options {
+		if o.Value == "" {
+			return 0, fmt.Errorf("select option %q has no value", o.Text)
+		}
+		if seen[o.Value] {
+			return 0, fmt.Errorf("duplicate select option value %q", o.Value)
+		}
+		seen[o.Value] = true
+	}
+	if e.InitialOption != "" && !seen[e.InitialOption] {
+		return 0, fmt.Errorf("initial option %q is not an option", e.InitialOption)
+	}
+	return 1, nil
+}
+
+// DatePickerElement lets the user choose a date
+type DatePickerElement struct {
+	Placeholder string
+	InitialDate string                 // Date selected initially, formatted "2006-01-02"
+	Name        string                 // Component name, reported in callbacks
+	Value       map[string]interface{} // Value sent in the card action callback with the chosen date
+}
+
+// DatePicker creates a date picker
+func DatePicker(placeholder string) *DatePickerElement {
+	return &DatePickerElement{Placeholder: placeholder}
+}
+
+// WithCallback sends value to the card action callback when a date is chosen
+func (e *DatePickerElement) WithCallback(value map[string]interface{}) *DatePickerElement {
+	e.Value = value
+	return e
+}
+
+func (e *DatePickerElement) toMap() map[string]interface{} {
+	m := map[string]interface{}{
+		"tag":         "date_picker",
+		"placeholder": plainText(e.Placeholder, nil),
+	}
+	if e.InitialDate != "" {
+		m["initial_date"] = e.InitialDate
+	}
+	if e.Name != "" {
+		m["name"] = e.Name
+	}
+	if e.Value != nil {
+		m["behaviors"] = callbackBehaviors("", e.Value)
+	}
+	return m
+}
+
+func (e *DatePickerElement) validate() (int, error) {
+	if e.InitialDate != "" {
+		if _, err := time.Parse("2006-01-02", e.InitialDate); err != nil {
+			return 0, fmt.Errorf("invalid initial date %q, expected YYYY-MM-DD", e.InitialDate)
+		}
+	}
+	return 1, nil
+}
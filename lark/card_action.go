@@ -0,0 +1,250 @@
+package lark
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ToastType is the style of a toast shown after a card action
+type ToastType string
+
+const (
+	ToastInfo    ToastType = "info"
+	ToastSuccess ToastType = "success"
+	ToastWarning ToastType = "warning"
+	ToastError   ToastType = "error"
+)
+
+// CardAction is a user interaction with an interactive component of a card
+type CardAction struct {
+	Tag       string                 // Component tag, e.g. "button", "select_static", "date_picker"
+	Name      string                 // Component name, if set
+	Value     map[string]interface{} // Callback value of the component
+	Option    string                 // Chosen option or date for select menus and pickers
+	FormValue map[string]interface{} // Submitted form fields, keyed by component name
+
+	OperatorOpenID string // open_id of the user who acted
+	OperatorUserID string // user_id of the user who acted, if the app may read it
+	MessageID      string // open_message_id of the card
+	ChatID         string // open_chat_id of the chat the card is in
+	Token          string // Token for updating the card later (valid for 30 minutes, twice)
+
+	Raw []byte // Raw (decrypted) callback body
+}
+
+// Toast is a short notice shown to the user after a card action
+type Toast struct {
+	Type    ToastType
+	Content string
+	I18n    map[string]string // Content per locale
+}
+
+// CardActionResponse is the synchronous answer to a card action
+type CardActionResponse struct {
+	Toast *Toast // Optional: notice shown to the operator, not supported by legacy card callbacks
+	Card  *Card  // Optional: card replacing the one that was acted on
+}
+
+// CardActionHandler handles a card action. A nil response acknowledges the action
+// without changing the card.
+type CardActionHandler func(ctx context.Context, action *CardAction) (*CardActionResponse, error)
+
+// cardActionEvent is the card.action.trigger event body, shared by the
+// schema 2.0 event and the legacy card callback (where the fields are top-level)
+type cardActionEvent struct {
+	Operator struct {
+		OpenID string `json:"open_id"`
+		UserID string `json:"user_id"`
+	} `json:"operator"`
+	Token  string `json:"token"`
+	Action struct {
+		Tag       string                 `json:"tag"`
+		Name      string                 `json:"name"`
+		Value     map[string]interface{} `json:"value"`
+		Option    string                 `json:"option"`
+		FormValue map[string]interface{} `json:"form_value"`
+	} `json:"action"`
+	Context struct {
+		OpenMessageID string `json:"open_message_id"`
+		OpenChatID    string `json:"open_chat_id"`
+	} `json:"context"`
+
+	// Legacy card callback fields
+	OpenID        string `json:"open_id"`
+	UserID        string `json:"user_id"`
+	OpenMessageID string `json:"open_message_id"`
+	OpenChatID    string `json:"open_chat_id"`
+}
+
+// toCardAction converts the event into a CardAction
+func (e *cardActionEvent) toCardAction(raw []byte) *CardAction {
+	action := &CardAction{
+		Tag:            e.Action.Tag,
+		Name:           e.Action.Name,
+		Value:          e.Action.Value,
+		Option:         e.Action.Option,
+		FormValue:      e.Action.FormValue,
+		OperatorOpenID: e.Operator.OpenID,
+		OperatorUserID: e.Operator.UserID,
+		MessageID:      e.Context.OpenMessageID,
+		ChatID:         e.Context.OpenChatID,
+		Token:          e.Token,
+		Raw:            raw,
+	}
+
+	// Legacy callbacks carry the operator and message at the top level
+	if action.OperatorOpenID == "" {
+		action.OperatorOpenID = e.OpenID
+		action.OperatorUserID = e.UserID
+	}
+	if action.MessageID == "" {
+		action.MessageID = e.OpenMessageID
+		action.ChatID = e.OpenChatID
+	}
+	return action
+}
+
+// body converts the response into the JSON returned to Lark. card.action.trigger
+// events take a toast and a wrapped card, legacy callbacks only the raw card.
+func (r *CardActionResponse) body(legacy bool) (interface{}, error) {
+	body := make(map[string]interface{})
+	if r == nil {
+		return body, nil
+	}
+
+	if legacy {
+		if r.Card == nil {
+			return body, nil
+		}
+		data, err := r.Card.JSON()
+		if err != nil {
+			return nil, fmt.Errorf("invalid card in response: %w", err)
+		}
+		return json.RawMessage(data), nil
+	}
+
+	if r.Toast != nil {
+		toastType := r.Toast.Type
+		if toastType == "" {
+			toastType = ToastInfo
+		}
+		toast := map[string]interface{}{
+			"type":    string(toastType),
+			"content": r.Toast.Content,
+		}
+		if len(r.Toast.I18n) > 0 {
+			toast["i18n"] = r.Toast.I18n
+		}
+		body["toast"] = toast
+	}
+
+	if r.Card != nil {
+		data, err := r.Card.JSON()
+		if err != nil {
+			return nil, fmt.Errorf("invalid card in response: %w", err)
+		}
+		body["card"] = map[string]interface{}{
+			"type": "raw",
+			"data": json.RawMessage(data),
+		}
+	}
+
+	return body, nil
+}
+
+// NewCardActionHandler returns an http.Handler for the card callback URL.
+// It answers the url_verification challenge, decrypts payloads when encryptKey
+// is set, and passes card actions (card.action.trigger events and legacy card
+// callbacks) to handler. Requests that fail verification are rejected with 401:
+// card.action.trigger events carry the verification token and, when encryptKey
+// is set, are signed with it as by EventDispatcher; legacy callbacks are signed
+// with the verification token. Empty keys skip the corresponding checks. The
+// handler's toast and updated card are returned in the response; Lark expects
+// it within 3 seconds.
+func NewCardActionHandler(verificationToken, encryptKey string, handler CardActionHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, err := io.ReadAll(io.LimitReader(r.Body, maxCallbackBody))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		body, envelope, err := parseEvent(raw, encryptKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Schema 2.0 events nest the action in "event", legacy callbacks don't and
+		// use "token" for the card update token
+		legacy := envelope.Type != typeURLVerification && envelope.Header.EventType == ""
+
+		if verificationToken != "" && !legacy && envelope.verificationToken() != verificationToken {
+			http.Error(w, "invalid verification token", http.StatusUnauthorized)
+			return
+		}
+
+		if envelope.Type == typeURLVerification {
+			writeJSON(w, map[string]string{"challenge": envelope.Challenge})
+			return
+		}
+
+		switch {
+		case legacy && verificationToken != "" && !verifyLegacySignature(r.Header, verificationToken, raw),
+			!legacy && encryptKey != "" && !verifySignature(r.Header, encryptKey, raw):
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		eventBody := []byte(envelope.Event)
+		if legacy {
+			eventBody = body
+		} else if envelope.Header.EventType != EventCardActionTrigger {
+			writeJSON(w, map[string]interface{}{})
+			return
+		}
+
+		var event cardActionEvent
+		if err := json.Unmarshal(eventBody, &event); err != nil {
+			http.Error(w, fmt.Sprintf("invalid card action: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		resp, err := handler(r.Context(), event.toCardAction(body))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		respBody, err := resp.body(legacy)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, respBody)
+	})
+}
+
+// verifyLegacySignature checks the X-Lark-Signature of a legacy card callback:
+// hex(sha1(timestamp + nonce + verificationToken + body))
+func verifyLegacySignature(header http.Header, verificationToken string, body []byte) bool {
+	signature := header.Get("X-Lark-Signature")
+	if signature == "" {
+		return false
+	}
+
+	h := sha1.New()
+	h.Write([]byte(header.Get("X-Lark-Request-Timestamp")))
+	h.Write([]byte(header.Get("X-Lark-Request-Nonce")))
+	h.Write([]byte(verificationToken))
+	h.Write(body)
+	expected := hex.EncodeToString(h.Sum(nil))
+
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(signature)) == 1
+}
@@ -0,0 +1,51 @@
+package lark
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+)
+
+// decrypt decrypts an event or card callback payload encrypted with the app's Encrypt Key.
+// Lark uses AES-256-CBC with key sha256(encryptKey); the IV is the first block of the ciphertext.
+// 参考: https://open.feishu.cn/document/server-docs/event-subscription-guide/event-subscription-configure-/encrypt-key-encryption-configuration-case
+func decrypt(encryptKey, encrypted string) ([]byte, error) {
+	buf, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypted payload: %w", err)
+	}
+	if len(buf) < aes.BlockSize || len(buf)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("invalid encrypted payload length %d", len(buf))
+	}
+
+	key := sha256.Sum256([]byte(encryptKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	iv, ciphertext := buf[:aes.BlockSize], buf[aes.BlockSize:]
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+
+	return pkcs7Unpad(plaintext, aes.BlockSize)
+}
+
+// pkcs7Unpad strips PKCS#7 padding
+func pkcs7Unpad(data []byte, blockSize int) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty plaintext")
+	}
+	pad := int(data[len(data)-1])
+	if pad == 0 || pad > blockSize || pad > len(data) {
+		return nil, fmt.Errorf("invalid padding")
+	}
+	for _, b := range data[len(data)-pad:] {
+		if int(b) != pad {
+			return nil, fmt.Errorf("invalid padding")
+		}
+	}
+	return data[:len(data)-pad], nil
+}
@@ -0,0 +1,71 @@
+package lark
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+// errorKinds maps Lark error codes to error kinds
+// 参考: https://open.feishu.cn/document/server-docs/api-call-guide/generic-error-code
+var errorKinds = map[int]types.ErrorKind{
+	99991400: types.ErrorKindRateLimited,    // request trigger frequency limit
+	230020:   types.ErrorKindRateLimited,    // this operation triggers the frequency limit
+	11232:    types.ErrorKindRateLimited,    // webhook robot frequency limited
+	19021:    types.ErrorKindAuth,           // webhook signature verification failed
+	19022:    types.ErrorKindAuth,           // webhook ip not allowed
+	19024:    types.ErrorKindInvalidRequest, // webhook keywords not found in content
+	99991661: types.ErrorKindTokenExpired,   // missing access token
+	99991663: types.ErrorKindTokenExpired,   // invalid tenant access token
+	99991668: types.ErrorKindTokenExpired,   // invalid access token
+	99991671: types.ErrorKindAuth,           // malformed access token
+	99991672: types.ErrorKindAuth,           // app lacks the required scope
+	99991679: types.ErrorKindAuth,           // user lacks the required permission
+	10003:    types.ErrorKindAuth,           // invalid app_id
+	10014:    types.ErrorKindAuth,           // invalid app_secret
+	230006:   types.ErrorKindAuth,           // bot ability is not activated
+	230002:   types.ErrorKindInvalidTarget,  // bot is not in the chat
+	230013:   types.ErrorKindInvalidTarget,  // bot has no availability to this user
+	230017:   types.ErrorKindInvalidTarget,  // bot is not the owner of the resource
+	99992351: types.ErrorKindInvalidTarget,  // open_id doesn't exist
+	99992361: types.ErrorKindInvalidTarget,  // open_id belongs to another app
+	99992364: types.ErrorKindInvalidTarget,  // user_id doesn't exist
+	230001:   types.ErrorKindInvalidRequest, // invalid request parameter
+	230099:   types.ErrorKindInvalidRequest, // failed to create card content
+	99992402: types.ErrorKindInvalidRequest, // field validation failed
+	234001:   types.ErrorKindInvalidRequest, // invalid upload request parameter
+	234006:   types.ErrorKindInvalidRequest, // uploaded file exceeds the size limit
+	234010:   types.ErrorKindInvalidRequest, // uploaded file is empty
+	234011:   types.ErrorKindInvalidRequest, // uploaded image can't be recognized
+	1500:     types.ErrorKindServer,         // internal error
+	230005:   types.ErrorKindServer,         // internal error, retry later
+}
+
+// newAPIError builds a typed error from a Lark response
+func newAPIError(resp *http.Response, code int, msg string) *types.APIError {
+	kind, ok := errorKinds[code]
+	if !ok && resp.StatusCode >= http.StatusInternalServerError {
+		kind = types.ErrorKindServer
+	}
+
+	return &types.APIError{
+		Platform:   "lark",
+		HTTPStatus: resp.StatusCode,
+		Code:       code,
+		Message:    msg,
+		RequestID:  resp.Header.Get("X-Tt-Logid"),
+		Kind:       kind,
+	}
+}
+
+// apiError builds a typed error and drops the cached token when the API rejected it
+func (c *Client) apiError(resp *http.Response, code int, msg string) *types.APIError {
+	apiErr := newAPIError(resp, code, msg)
+	if apiErr.Kind == types.ErrorKindTokenExpired {
+		c.tokenMu.Lock()
+		c.tokenExpiry = time.Time{}
+		c.tokenMu.Unlock()
+	}
+	return apiErr
+}
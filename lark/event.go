@@ -0,0 +1,428 @@
+package lark
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Event types dispatched by EventDispatcher
+// 参考: https://open.feishu.cn/document/server-docs/event-subscription-guide/event-list
+const (
+	EventMessageReceive    = "im.message.receive_v1"
+	EventMessageRead       = "im.message.message_read_v1"
+	EventChatBotAdded      = "im.chat.member.bot.added_v1"
+	EventChatBotDeleted    = "im.chat.member.bot.deleted_v1"
+	EventChatMemberAdded   = "im.chat.member.user.added_v1"
+	EventChatMemberDeleted = "im.chat.member.user.deleted_v1"
+	EventChatDisbanded     = "im.chat.disbanded_v1"
+	EventCardActionTrigger = "card.action.trigger"
+)
+
+const (
+	// typeURLVerification is the type of the challenge request sent when the request URL is saved
+	typeURLVerification = "url_verification"
+
+	// maxCallbackBody limits the size of an event callback body
+	maxCallbackBody = 1 << 20
+
+	// eventDedupWindow covers Lark's redelivery schedule (15s, 5m, 1h and 6h after a failure)
+	eventDedupWindow = 8 * time.Hour
+
+	// maxSeenEvents caps the event IDs kept for dedup, the oldest are dropped first
+	maxSeenEvents = 4096
+)
+
+// eventEnvelope is the outer structure of an event callback (schema 2.0),
+// including the fields of the url_verification request
+type eventEnvelope struct {
+	// url_verification (and schema 1.0) fields
+	Challenge string `json:"challenge"`
+	Token     string `json:"token"`
+	Type      string `json:"type"`
+
+	// schema 2.0 fields
+	Schema string          `json:"schema"`
+	Header EventHeader     `json:"header"`
+	Event  json.RawMessage `json:"event"`
+}
+
+// verificationToken returns the token carried by the callback, whatever its schema
+func (e *eventEnvelope) verificationToken() string {
+	if e.Header.Token != "" {
+		return e.Header.Token
+	}
+	return e.Token
+}
+
+// EventHeader is the header of a schema 2.0 event callback
+type EventHeader struct {
+	EventID    string `json:"event_id"`
+	EventType  string `json:"event_type"`
+	CreateTime string `json:"create_time"`
+	Token      string `json:"token"`
+	AppID      string `json:"app_id"`
+	TenantKey  string `json:"tenant_key"`
+}
+
+// EventMeta is embedded in typed events to carry the envelope they came in
+type EventMeta struct {
+	Header EventHeader
+	Raw    []byte // Raw (decrypted) callback body
+}
+
+// setMeta stores the envelope in a typed event
+func (m *EventMeta) setMeta(meta EventMeta) {
+	*m = meta
+}
+
+// Event is an event callback passed to handlers registered with EventDispatcher.On
+type Event struct {
+	EventMeta
+	Event json.RawMessage // The "event" object, to be decoded according to Header.EventType
+}
+
+// decode unmarshals the event object into a typed event and attaches the envelope
+func (e *Event) decode(typed interface{ setMeta(EventMeta) }) error {
+	if err := json.Unmarshal(e.Event, typed); err != nil {
+		return fmt.Errorf("invalid %s event: %w", e.Header.EventType, err)
+	}
+	typed.setMeta(e.EventMeta)
+	return nil
+}
+
+// MessageReadEvent is the payload of the im.message.message_read_v1 event
+type MessageReadEvent struct {
+	EventMeta `json:"-"`
+
+	Reader struct {
+		ReaderID  UserID `json:"reader_id"`
+		ReadTime  string `json:"read_time"` // Milliseconds since epoch, as a string
+		TenantKey string `json:"tenant_key"`
+	} `json:"reader"`
+	MessageIDList []string `json:"message_id_list"`
+}
+
+// ChatBotEvent is the payload of the im.chat.member.bot.added_v1 and
+// im.chat.member.bot.deleted_v1 events
+type ChatBotEvent struct {
+	EventMeta `json:"-"`
+
+	ChatID            string            `json:"chat_id"`
+	OperatorID        UserID            `json:"operator_id"` // Who added or removed the bot
+	External          bool              `json:"external"`    // Whether the chat is an external chat
+	OperatorTenantKey string            `json:"operator_tenant_key"`
+	Name              string            `json:"name"` // Chat name
+	I18nNames         map[string]string `json:"i18n_names"`
+}
+
+// ChatMemberEvent is the payload of the im.chat.member.user.added_v1 and
+// im.chat.member.user.deleted_v1 events
+type ChatMemberEvent struct {
+	EventMeta `json:"-"`
+
+	ChatID     string `json:"chat_id"`
+	OperatorID UserID `json:"operator_id"`
+	External   bool   `json:"external"`
+	Name       string `json:"name"` // Chat name
+	Users      []struct {
+		Name      string `json:"name"`
+		TenantKey string `json:"tenant_key"`
+		UserID    UserID `json:"user_id"`
+	} `json:"users"`
+}
+
+// EventHandler handles an event. Returning an error makes the dispatcher answer
+// with HTTP 500, so Lark redelivers the event.
+type EventHandler func(ctx context.Context, event *Event) error
+
+// EventDispatcher is an http.Handler for the event subscription request URL.
+// It answers the url_verification challenge, checks the verification token,
+// verifies X-Lark-Signature and decrypts payloads when an Encrypt Key is
+// configured, drops redelivered events by event_id (answering 409 while the
+// first delivery is still being handled), and dispatches events to
+// the handlers registered for their type. Events without a handler are
+// acknowledged and ignored. Register handlers before serving requests.
+//
+// Lark expects an answer within 3 seconds, so long-running work should be
+// moved out of the handlers.
+type EventDispatcher struct {
+	verificationToken string
+	encryptKey        string
+	handlers          map[string]EventHandler
+
+	mu    sync.Mutex
+	seen  map[string]*seenEvent // event_id -> delivery state
+	order *list.List            // event_ids in seen, oldest first
+}
+
+// seenEvent is the delivery state of an event ID
+type seenEvent struct {
+	at   time.Time     // When it was first received
+	done bool          // Whether a handler succeeded, false while it's running
+	elem *list.Element // Position in EventDispatcher.order
+}
+
+// NewEventDispatcher creates a dispatcher using config's VerificationToken and EncryptKey
+func NewEventDispatcher(config *Config) *EventDispatcher {
+	return &EventDispatcher{
+		verificationToken: config.VerificationToken,
+		encryptKey:        config.EncryptKey,
+		handlers:          make(map[string]EventHandler),
+		seen:              make(map[string]*seenEvent),
+		order:             list.New(),
+	}
+}
+
+// On registers the handler of an event type, e.g. "contact.user.created_v3"
+func (d *EventDispatcher) On(eventType string, handler EventHandler) *EventDispatcher {
+	d.handlers[eventType] = handler
+	return d
+}
+
+// OnMessageReceive handles messages sent to the bot (im.message.receive_v1)
+func (d *EventDispatcher) OnMessageReceive(handler func(ctx context.Context, event *MessageReceiveEvent) error) *EventDispatcher {
+	return d.On(EventMessageReceive, func(ctx context.Context, event *Event) error {
+		typed := &MessageReceiveEvent{}
+		if err := event.decode(typed); err != nil {
+			return err
+		}
+		return handler(ctx, typed)
+	})
+}
+
+// OnMessageRead handles read receipts of messages sent by the bot (im.message.message_read_v1)
+func (d *EventDispatcher) OnMessageRead(handler func(ctx context.Context, event *MessageReadEvent) error) *EventDispatcher {
+	return d.On(EventMessageRead, func(ctx context.Context, event *Event) error {
+		typed := &MessageReadEvent{}
+		if err := event.decode(typed); err != nil {
+			return err
+		}
+		return handler(ctx, typed)
+	})
+}
+
+// OnBotAdded handles the bot being added to a chat (im.chat.member.bot.added_v1)
+func (d *EventDispatcher) OnBotAdded(handler func(ctx context.Context, event *ChatBotEvent) error) *EventDispatcher {
+	return d.On(EventChatBotAdded, func(ctx context.Context, event *Event) error {
+		typed := &ChatBotEvent{}
+		if err := event.decode(typed); err != nil {
+			return err
+		}
+		return handler(ctx, typed)
+	})
+}
+
+// OnBotDeleted handles the bot being removed from a chat (im.chat.member.bot.deleted_v1)
+func (d *EventDispatcher) OnBotDeleted(handler func(ctx context.Context, event *ChatBotEvent) error) *EventDispatcher {
+	return d.On(EventChatBotDeleted, func(ctx context.Context, event *Event) error {
+		typed := &ChatBotEvent{}
+		if err := event.decode(typed); err != nil {
+			return err
+		}
+		return handler(ctx, typed)
+	})
+}
+
+// OnMemberAdded handles users joining a chat the bot is in (im.chat.member.user.added_v1)
+func (d *EventDispatcher) OnMemberAdded(handler func(ctx context.Context, event *ChatMemberEvent) error) *EventDispatcher {
+	return d.On(EventChatMemberAdded, func(ctx context.Context, event *Event) error {
+		typed := &ChatMemberEvent{}
+		if err := event.decode(typed); err != nil {
+			return err
+		}
+		return handler(ctx, typed)
+	})
+}
+
+// OnMemberDeleted handles users leaving a chat the bot is in (im.chat.member.user.deleted_v1)
+func (d *EventDispatcher) OnMemberDeleted(handler func(ctx context.Context, event *ChatMemberEvent) error) *EventDispatcher {
+	return d.On(EventChatMemberDeleted, func(ctx context.Context, event *Event) error {
+		typed := &ChatMemberEvent{}
+		if err := event.decode(typed); err != nil {
+			return err
+		}
+		return handler(ctx, typed)
+	})
+}
+
+// ServeHTTP implements http.Handler
+func (d *EventDispatcher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	raw, err := io.ReadAll(io.LimitReader(r.Body, maxCallbackBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body, envelope, err := parseEvent(raw, d.encryptKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if d.verificationToken != "" && envelope.verificationToken() != d.verificationToken {
+		http.Error(w, "invalid verification token", http.StatusUnauthorized)
+		return
+	}
+
+	if envelope.Type == typeURLVerification {
+		writeJSON(w, map[string]string{"challenge": envelope.Challenge})
+		return
+	}
+
+	if d.encryptKey != "" && !verifySignature(r.Header, d.encryptKey, raw) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	handler, ok := d.handlers[envelope.Header.EventType]
+	if !ok {
+		writeJSON(w, map[string]interface{}{})
+		return
+	}
+
+	eventID := envelope.Header.EventID
+	switch d.begin(eventID) {
+	case eventDone:
+		// Already handled, acknowledge the redelivery
+		writeJSON(w, map[string]interface{}{})
+		return
+	case eventRunning:
+		// Let Lark redeliver it later, the running handler may still fail
+		http.Error(w, "event is being handled", http.StatusConflict)
+		return
+	}
+
+	event := &Event{
+		EventMeta: EventMeta{Header: envelope.Header, Raw: body},
+		Event:     envelope.Event,
+	}
+	err = handler(r.Context(), event)
+	d.finish(eventID, err == nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{})
+}
+
+// eventState is what begin found for an event ID
+type eventState int
+
+const (
+	eventNew     eventState = iota // Not seen within the dedup window, now marked as running
+	eventRunning                   // A handler is running for it
+	eventDone                      // A handler succeeded for it
+)
+
+// begin checks whether an event was seen within the dedup window and, if not,
+// marks it as running, in a single critical section so that concurrent
+// deliveries of an event don't both run its handler
+func (d *EventDispatcher) begin(eventID string) eventState {
+	if eventID == "" {
+		return eventNew
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	if e, ok := d.seen[eventID]; ok && now.Sub(e.at) < eventDedupWindow {
+		if e.done {
+			return eventDone
+		}
+		return eventRunning
+	}
+
+	// Drop expired events, and the oldest ones beyond the cap
+	if e, ok := d.seen[eventID]; ok {
+		d.order.Remove(e.elem)
+		delete(d.seen, eventID)
+	}
+	for front := d.order.Front(); front != nil; front = d.order.Front() {
+		id := front.Value.(string)
+		if now.Sub(d.seen[id].at) < eventDedupWindow && len(d.seen) < maxSeenEvents {
+			break
+		}
+		d.order.Remove(front)
+		delete(d.seen, id)
+	}
+
+	d.seen[eventID] = &seenEvent{at: now, elem: d.order.PushBack(eventID)}
+	return eventNew
+}
+
+// finish marks a running event as done, or removes it after a failure so that
+// its redelivery is handled again
+func (d *EventDispatcher) finish(eventID string, ok bool) {
+	if eventID == "" {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if ok {
+		if e, found := d.seen[eventID]; found {
+			e.done = true
+		}
+		return
+	}
+	if e, found := d.seen[eventID]; found {
+		d.order.Remove(e.elem)
+		delete(d.seen, eventID)
+	}
+}
+
+// verifySignature checks X-Lark-Signature = hex(sha256(timestamp + nonce + encryptKey + body))
+func verifySignature(header http.Header, encryptKey string, body []byte) bool {
+	signature := header.Get("X-Lark-Signature")
+	if signature == "" {
+		return false
+	}
+
+	h := sha256.New()
+	h.Write([]byte(header.Get("X-Lark-Request-Timestamp")))
+	h.Write([]byte(header.Get("X-Lark-Request-Nonce")))
+	h.Write([]byte(encryptKey))
+	h.Write(body)
+	expected := hex.EncodeToString(h.Sum(nil))
+
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(signature)) == 1
+}
+
+// parseEvent decrypts a callback body when it carries an "encrypt" field and
+// decodes its envelope; the returned body is the decrypted JSON
+func parseEvent(body []byte, encryptKey string) ([]byte, *eventEnvelope, error) {
+	var encrypted struct {
+		Encrypt string `json:"encrypt"`
+	}
+	if err := json.Unmarshal(body, &encrypted); err != nil {
+		return nil, nil, fmt.Errorf("invalid callback body: %w", err)
+	}
+
+	if encrypted.Encrypt != "" {
+		if encryptKey == "" {
+			return nil, nil, fmt.Errorf("received an encrypted callback but no encrypt key is configured")
+		}
+		var err error
+		if body, err = decrypt(encryptKey, encrypted.Encrypt); err != nil {
+			return nil, nil, fmt.Errorf("failed to decrypt callback: %w", err)
+		}
+	}
+
+	var envelope eventEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, nil, fmt.Errorf("invalid callback body: %w", err)
+	}
+
+	return body, &envelope, nil
+}
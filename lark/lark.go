@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,18 +16,31 @@ import (
 )
 
 const (
-	// Lark API endpoints
-	tokenURL       = "https://open.feishu.cn/open-apis/auth/v3/tenant_access_token/internal"
-	sendMessageURL = "https://open.feishu.cn/open-apis/im/v1/messages"
-	batchGetIDURL  = "https://open.feishu.cn/open-apis/contact/v3/users/batch_get_id"
+	// FeishuBaseURL is the open platform of Feishu (China), used by default
+	FeishuBaseURL = "https://open.feishu.cn"
+	// LarkSuiteBaseURL is the open platform of Lark Suite (international)
+	LarkSuiteBaseURL = "https://open.larksuite.com"
+
+	// Lark API endpoints, relative to the base URL
+	tokenPath       = "/open-apis/auth/v3/tenant_access_token/internal"
+	sendMessagePath = "/open-apis/im/v1/messages"
+	batchGetIDPath  = "/open-apis/contact/v3/users/batch_get_id"
+	imagesPath      = "/open-apis/im/v1/images"
+	filesPath       = "/open-apis/im/v1/files"
 )
 
 // Config represents Lark/Feishu configuration
 type Config struct {
-	AppID      string
-	AppSecret  string
-	BaseURL    string // Optional: custom base URL
-	WebhookURL string // Optional: webhook URL for group robot
+	AppID       string
+	AppSecret   string
+	BaseURL     string             // Optional: open platform base URL, e.g. LarkSuiteBaseURL, a private deployment or a test server (default: FeishuBaseURL)
+	WebhookURL  string             // Optional: webhook URL for group robot
+	RetryPolicy *types.RetryPolicy // Optional: retry policy for failed sends (default: types.DefaultRetryPolicy())
+	RateLimit   *types.RateLimit   // Optional: client-side rate limit (default: DefaultRateLimit(), &types.RateLimit{} disables it)
+	Concurrency int                // Optional: targets sent to in parallel by SendMessage (default: 1)
+
+	VerificationToken string // Optional: Verification Token of event subscriptions, checked by NewEventDispatcher
+	EncryptKey        string // Optional: Encrypt Key of event subscriptions, used to verify signatures and decrypt events
 }
 
 // Validate validates the config
@@ -49,11 +64,38 @@ func (c *Config) GetPlatform() string {
 	return "lark"
 }
 
+// DefaultRateLimit returns the quotas applied when Config.RateLimit is nil:
+// 50 messages per second per app and 5 per second to the same user or chat.
+// Webhook robots are limited to 100 messages per minute with bursts of 5.
+func DefaultRateLimit(webhook bool) *types.RateLimit {
+	if webhook {
+		return &types.RateLimit{Rate: types.PerMinute(100), Burst: 5}
+	}
+	return &types.RateLimit{Rate: 50, Burst: 50, PerTargetRate: 5, PerTargetBurst: 5}
+}
+
+// MessageLimit returns the content length limit of a message type, used to split
+// messages when SendOptions.Split is set. Lark caps app API request bodies at
+// 150 KB for text and 30 KB for posts and cards, and webhook robot request bodies
+// at 20 KB; the limits leave room for the JSON envelope.
+func MessageLimit(msgType types.MessageType, webhook bool) types.Limit {
+	if webhook {
+		return types.Limit{Max: 18 << 10, Unit: types.LengthBytes}
+	}
+	if msgType == types.MessageTypeText {
+		return types.Limit{Max: 140 << 10, Unit: types.LengthBytes}
+	}
+	return types.Limit{Max: 28 << 10, Unit: types.LengthBytes}
+}
+
 // Client implements IMParrot interface for Lark/Feishu
 type Client struct {
 	config      *Config
 	httpClient  *http.Client
 	ownsHTTP    bool // Whether the client owns the http.Client and should close it
+	retry       *types.RetryPolicy
+	limiter     *types.RateLimiter
+	baseURL     string
 	token       string
 	tokenMu     sync.RWMutex
 	tokenExpiry time.Time
@@ -69,10 +111,28 @@ func NewClient(config *Config, httpClient *http.Client) (*Client, error) {
 		ownsHTTP = true
 	}
 
+	retry := config.RetryPolicy
+	if retry == nil {
+		retry = types.DefaultRetryPolicy()
+	}
+
+	rateLimit := config.RateLimit
+	if rateLimit == nil {
+		rateLimit = DefaultRateLimit(config.WebhookURL != "")
+	}
+
+	baseURL := strings.TrimRight(config.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = FeishuBaseURL
+	}
+
 	client := &Client{
 		config:     config,
 		httpClient: httpClient,
 		ownsHTTP:   ownsHTTP,
+		retry:      retry,
+		limiter:    types.NewRateLimiter("lark", rateLimit),
+		baseURL:    baseURL,
 	}
 
 	// Get initial access token only if not in webhook mode
@@ -102,7 +162,7 @@ func (c *Client) refreshToken(ctx context.Context) error {
 		return err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+tokenPath, bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
@@ -131,7 +191,7 @@ func (c *Client) refreshToken(ctx context.Context) error {
 	}
 
 	if tokenResp.Code != 0 {
-		return fmt.Errorf("failed to get token: %s", tokenResp.Msg)
+		return newAPIError(resp, tokenResp.Code, tokenResp.Msg)
 	}
 
 	c.tokenMu.Lock()
@@ -164,70 +224,67 @@ func (c *Client) getToken(ctx context.Context) (string, error) {
 
 // SendMessage sends a message with options (Strategy pattern implementation)
 func (c *Client) SendMessage(ctx context.Context, msg *types.Message, opts *types.SendOptions) error {
+	_, err := c.SendMessageWithResult(ctx, msg, opts)
+	return err
+}
+
+// SendMessageWithResult sends a message and returns a receipt for every delivered target
+func (c *Client) SendMessageWithResult(ctx context.Context, msg *types.Message, opts *types.SendOptions) ([]types.SendResult, error) {
 	if msg == nil || opts == nil {
-		return fmt.Errorf("message and options cannot be nil")
+		return nil, fmt.Errorf("message and options cannot be nil")
 	}
 
 	// If webhook URL is configured, use webhook mode (doesn't require targets)
 	if c.config.WebhookURL != "" {
-		return c.sendViaWebhook(ctx, msg, opts)
+		result, err := types.SendSplit(ctx, msg, opts, MessageLimit(msg.Type, true), func(ctx context.Context, msg *types.Message) (*types.SendResult, error) {
+			var result *types.SendResult
+			err := c.retry.Do(ctx, func(ctx context.Context) error {
+				var err error
+				result, err = c.sendViaWebhook(ctx, msg, opts)
+				return err
+			})
+			return result, err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return []types.SendResult{*result}, nil
 	}
 
 	// For standard API mode, at least one target is required
 	if len(opts.Targets) == 0 {
-		return fmt.Errorf("at least one target is required")
-	}
-
-	// Send to multiple targets with retry
-	const maxRetries = 3
-	failedTargets := make([]types.FailedTarget, 0)
-	successCount := 0
-
-	for _, target := range opts.Targets {
-		var lastErr error
-		sent := false
-
-		// Retry up to maxRetries times for each target
-		for retry := 0; retry < maxRetries; retry++ {
-			if err := c.sendToSingleTarget(ctx, msg, target); err != nil {
-				lastErr = err
-				// Wait a bit before retrying (exponential backoff)
-				if retry < maxRetries-1 {
-					time.Sleep(time.Duration(100*(retry+1)) * time.Millisecond)
-				}
-			} else {
-				sent = true
-				successCount++
-				break
-			}
-		}
-
-		// Record failed target after all retries exhausted
-		if !sent {
-			failedTargets = append(failedTargets, types.FailedTarget{
-				Target: target,
-				Error:  lastErr,
-			})
-		}
+		return nil, fmt.Errorf("at least one target is required")
 	}
 
-	// Return error with failed targets information
-	if len(failedTargets) > 0 {
-		return &types.SendError{
-			FailedTargets: failedTargets,
-			SuccessCount:  successCount,
-			TotalCount:    len(opts.Targets),
-		}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = c.config.Concurrency
 	}
 
-	return nil
+	// Fan out to the targets, retrying each (part) according to the retry policy
+	return types.FanOut(ctx, opts.Targets, concurrency, func(ctx context.Context, target types.Target) (*types.SendResult, error) {
+		return types.SendSplit(ctx, msg, opts, MessageLimit(msg.Type, false), func(ctx context.Context, msg *types.Message) (*types.SendResult, error) {
+			var result *types.SendResult
+			err := c.retry.Do(ctx, func(ctx context.Context) error {
+				var err error
+				result, err = c.sendToSingleTarget(ctx, msg, target)
+				return err
+			})
+			return result, err
+		})
+	})
 }
 
 // sendToSingleTarget sends a message to a single target
-func (c *Client) sendToSingleTarget(ctx context.Context, msg *types.Message, target types.Target) error {
+func (c *Client) sendToSingleTarget(ctx context.Context, msg *types.Message, target types.Target) (*types.SendResult, error) {
+	// Wait for the client-wide and per-target quotas before every attempt
+	if err := c.limiter.Wait(ctx, target.Key()); err != nil {
+		return nil, err
+	}
+
 	token, err := c.getToken(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get access token: %w", err)
+		return nil, fmt.Errorf("failed to get access token: %w", err)
 	}
 
 	// Determine receive_id_type based on chat type
@@ -237,8 +294,80 @@ func (c *Client) sendToSingleTarget(ctx context.Context, msg *types.Message, tar
 	}
 
 	// Build message content based on type
-	var content string
-	var msgType string
+	msgType, content := buildContent(msg)
+
+	reqBody := map[string]interface{}{
+		"receive_id": target.ID,
+		"msg_type":   msgType,
+		"content":    content,
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s%s?receive_id_type=%s", c.baseURL, sendMessagePath, receiveIDType)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var apiResp struct {
+		Code int         `json:"code"`
+		Msg  string      `json:"msg"`
+		Data messageData `json:"data"`
+	}
+
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return nil, err
+	}
+
+	if apiResp.Code != 0 {
+		return nil, c.apiError(resp, apiResp.Code, apiResp.Msg)
+	}
+
+	return apiResp.Data.toResult(target, respBody), nil
+}
+
+// messageData is the message object returned by the Lark message APIs
+type messageData struct {
+	MessageID  string `json:"message_id"`
+	ChatID     string `json:"chat_id"`
+	CreateTime string `json:"create_time"` // Milliseconds since epoch, as a string
+}
+
+// toResult converts the returned message object into a send receipt
+func (d messageData) toResult(target types.Target, raw []byte) *types.SendResult {
+	timestamp := time.Now()
+	if ms, err := strconv.ParseInt(d.CreateTime, 10, 64); err == nil && ms > 0 {
+		timestamp = time.UnixMilli(ms)
+	}
+	return &types.SendResult{
+		Target:    target,
+		MessageID: d.MessageID,
+		ChatID:    d.ChatID,
+		Timestamp: timestamp,
+		Raw:       raw,
+	}
+}
+
+// buildContent converts a unified message into Lark's msg_type and JSON-encoded content
+func buildContent(msg *types.Message) (msgType string, content string) {
 	switch msg.Type {
 	case types.MessageTypeText:
 		// Text message - supports line breaks, @mentions, style tags, and hyperlinks
@@ -311,56 +440,17 @@ func (c *Client) sendToSingleTarget(ctx context.Context, msg *types.Message, tar
 		msgType = string(msg.Type)
 	}
 
-	reqBody := map[string]interface{}{
-		"receive_id": target.ID,
-		"msg_type":   msgType,
-		"content":    content,
-	}
-
-	body, err := json.Marshal(reqBody)
-	if err != nil {
-		return err
-	}
-
-	url := fmt.Sprintf("%s?receive_id_type=%s", sendMessageURL, receiveIDType)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return err
-	}
-
-	var apiResp struct {
-		Code int    `json:"code"`
-		Msg  string `json:"msg"`
-	}
-
-	if err := json.Unmarshal(respBody, &apiResp); err != nil {
-		return err
-	}
-
-	if apiResp.Code != 0 {
-		return fmt.Errorf("lark API error: %s", apiResp.Msg)
-	}
-
-	return nil
+	return msgType, content
 }
 
 // sendViaWebhook sends a message via webhook URL
-// 用不着opts
-func (c *Client) sendViaWebhook(ctx context.Context, msg *types.Message, opts *types.SendOptions) error {
+// opts 仅用于在回执中记录目标, webhook 机器人总是发送到其所在的群
+func (c *Client) sendViaWebhook(ctx context.Context, msg *types.Message, opts *types.SendOptions) (*types.SendResult, error) {
+	// The robot has a single quota for its group
+	if err := c.limiter.Wait(ctx, ""); err != nil {
+		return nil, err
+	}
+
 	// Build webhook message body
 	var reqBody map[string]interface{}
 
@@ -391,7 +481,7 @@ func (c *Client) sendViaWebhook(ctx context.Context, msg *types.Message, opts *t
 		// Parse the card JSON from msg.Content
 		var cardData map[string]interface{}
 		if err := json.Unmarshal([]byte(msg.Content), &cardData); err != nil {
-			return fmt.Errorf("invalid card JSON: %w", err)
+			return nil, types.Permanent(fmt.Errorf("invalid card JSON: %w", err))
 		}
 		reqBody = map[string]interface{}{
 			"msg_type": "interactive",
@@ -415,24 +505,24 @@ func (c *Client) sendViaWebhook(ctx context.Context, msg *types.Message, opts *t
 
 	body, err := json.Marshal(reqBody)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", c.config.WebhookURL, bytes.NewReader(body))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var apiResp struct {
@@ -441,14 +531,23 @@ func (c *Client) sendViaWebhook(ctx context.Context, msg *types.Message, opts *t
 	}
 
 	if err := json.Unmarshal(respBody, &apiResp); err != nil {
-		return err
+		return nil, err
 	}
 
 	if apiResp.Code != 0 {
-		return fmt.Errorf("lark webhook error: %s", apiResp.Msg)
+		return nil, newAPIError(resp, apiResp.Code, apiResp.Msg)
 	}
 
-	return nil
+	// The webhook robot always posts to its own group and returns no message ID
+	var target types.Target
+	if len(opts.Targets) > 0 {
+		target = opts.Targets[0]
+	}
+	return &types.SendResult{
+		Target:    target,
+		Timestamp: time.Now(),
+		Raw:       respBody,
+	}, nil
 }
 
 // SendPrivateMessage sends a private message to a user
@@ -481,7 +580,7 @@ func (c *Client) GetOpenIDByMobile(ctx context.Context, mobile string) (string,
 		return "", err
 	}
 
-	url := fmt.Sprintf("%s?user_id_type=open_id", batchGetIDURL)
+	url := fmt.Sprintf("%s%s?user_id_type=open_id", c.baseURL, batchGetIDPath)
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
 		return "", err
@@ -517,7 +616,7 @@ func (c *Client) GetOpenIDByMobile(ctx context.Context, mobile string) (string,
 	}
 
 	if apiResp.Code != 0 {
-		return "", fmt.Errorf("lark API error: %s", apiResp.Msg)
+		return "", c.apiError(resp, apiResp.Code, apiResp.Msg)
 	}
 
 	if len(apiResp.Data.UserList) == 0 {
@@ -543,7 +642,7 @@ func (c *Client) GetOpenIDByEmail(ctx context.Context, email string) (string, er
 		return "", err
 	}
 
-	url := fmt.Sprintf("%s?user_id_type=open_id", batchGetIDURL)
+	url := fmt.Sprintf("%s%s?user_id_type=open_id", c.baseURL, batchGetIDPath)
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
 		return "", err
@@ -579,7 +678,7 @@ func (c *Client) GetOpenIDByEmail(ctx context.Context, email string) (string, er
 	}
 
 	if apiResp.Code != 0 {
-		return "", fmt.Errorf("lark API error: %s", apiResp.Msg)
+		return "", c.apiError(resp, apiResp.Code, apiResp.Msg)
 	}
 
 	if len(apiResp.Data.UserList) == 0 {
@@ -589,6 +688,98 @@ func (c *Client) GetOpenIDByEmail(ctx context.Context, email string) (string, er
 	return apiResp.Data.UserList[0].UserID, nil
 }
 
+// UpdateMessage edits a sent message in place.
+// Interactive cards are patched (PATCH im/v1/messages/:message_id); text and post
+// messages are edited (PUT im/v1/messages/:message_id). Webhook robots can't edit messages.
+func (c *Client) UpdateMessage(ctx context.Context, receipt *types.SendResult, msg *types.Message) error {
+	if receipt == nil || receipt.MessageID == "" || msg == nil {
+		return fmt.Errorf("receipt with message ID and message cannot be nil")
+	}
+	if c.config.WebhookURL != "" {
+		return &types.UnsupportedError{Platform: "lark webhook", Operation: "UpdateMessage"}
+	}
+
+	msgType, content := buildContent(msg)
+	url := fmt.Sprintf("%s%s/%s", c.baseURL, sendMessagePath, receipt.MessageID)
+
+	if msgType == "interactive" {
+		_, err := c.doAPI(ctx, "PATCH", url, map[string]interface{}{"content": content})
+		return err
+	}
+
+	_, err := c.doAPI(ctx, "PUT", url, map[string]interface{}{
+		"msg_type": msgType,
+		"content":  content,
+	})
+	return err
+}
+
+// RecallMessage withdraws a sent message (DELETE im/v1/messages/:message_id)
+func (c *Client) RecallMessage(ctx context.Context, receipt *types.SendResult) error {
+	if receipt == nil || receipt.MessageID == "" {
+		return fmt.Errorf("receipt with message ID cannot be nil")
+	}
+	if c.config.WebhookURL != "" {
+		return &types.UnsupportedError{Platform: "lark webhook", Operation: "RecallMessage"}
+	}
+
+	_, err := c.doAPI(ctx, "DELETE", fmt.Sprintf("%s%s/%s", c.baseURL, sendMessagePath, receipt.MessageID), nil)
+	return err
+}
+
+// doAPI performs an authenticated Open API call and returns the response body
+// once the API has reported success
+func (c *Client) doAPI(ctx context.Context, method, url string, reqBody interface{}) ([]byte, error) {
+	token, err := c.getToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	var bodyReader io.Reader
+	if reqBody != nil {
+		body, err := json.Marshal(reqBody)
+		if err != nil {
+			return nil, err
+		}
+		bodyReader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var apiResp struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return nil, err
+	}
+
+	if apiResp.Code != 0 {
+		return nil, c.apiError(resp, apiResp.Code, apiResp.Msg)
+	}
+
+	return respBody, nil
+}
+
 // Close releases all resources held by the client
 func (c *Client) Close() error {
 	c.closedMu.Lock()
@@ -0,0 +1,166 @@
+package lark
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+// UserID holds the IDs of a user in the different ID systems
+type UserID struct {
+	OpenID  string `json:"open_id"`
+	UserID  string `json:"user_id"`
+	UnionID string `json:"union_id"`
+}
+
+// MessageReceiveEvent is the payload of the im.message.receive_v1 event
+type MessageReceiveEvent struct {
+	EventMeta `json:"-"`
+
+	Sender struct {
+		SenderID   UserID `json:"sender_id"`
+		SenderType string `json:"sender_type"` // "user" or "app"
+		TenantKey  string `json:"tenant_key"`
+	} `json:"sender"`
+	Message struct {
+		MessageID   string         `json:"message_id"`
+		RootID      string         `json:"root_id"`
+		ParentID    string         `json:"parent_id"`
+		CreateTime  string         `json:"create_time"` // Milliseconds since epoch, as a string
+		ChatID      string         `json:"chat_id"`
+		ChatType    string         `json:"chat_type"` // "p2p" or "group"
+		MessageType string         `json:"message_type"`
+		Content     string         `json:"content"` // JSON-encoded content, depends on MessageType
+		Mentions    []EventMention `json:"mentions"`
+	} `json:"message"`
+}
+
+// EventMention is a user mentioned in a received message
+type EventMention struct {
+	Key       string `json:"key"` // Placeholder in the text, e.g. "@_user_1"
+	ID        UserID `json:"id"`
+	Name      string `json:"name"`
+	TenantKey string `json:"tenant_key"`
+}
+
+// Text extracts the plain text of a text or post message, with mention
+// placeholders replaced by "@name"
+func (e *MessageReceiveEvent) Text() string {
+	var text string
+	switch e.Message.MessageType {
+	case "text":
+		var content struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal([]byte(e.Message.Content), &content); err == nil {
+			text = content.Text
+		}
+	case "post":
+		text = postText(e.Message.Content)
+	}
+
+	for _, m := range e.Message.Mentions {
+		text = strings.ReplaceAll(text, m.Key, "@"+m.Name)
+	}
+	return text
+}
+
+// ToIncoming normalizes the event into a types.IncomingMessage
+func (e *MessageReceiveEvent) ToIncoming(raw []byte) *types.IncomingMessage {
+	chatType := types.ChatTypeGroup
+	if e.Message.ChatType == "p2p" {
+		chatType = types.ChatTypePrivate
+	}
+
+	timestamp := time.Now()
+	if ms, err := strconv.ParseInt(e.Message.CreateTime, 10, 64); err == nil && ms > 0 {
+		timestamp = time.UnixMilli(ms)
+	}
+
+	mentions := make([]types.Mention, 0, len(e.Message.Mentions))
+	for _, m := range e.Message.Mentions {
+		mentions = append(mentions, types.Mention{ID: m.ID.OpenID, Name: m.Name})
+	}
+
+	return &types.IncomingMessage{
+		Platform:  "lark",
+		ChatType:  chatType,
+		ChatID:    e.Message.ChatID,
+		MessageID: e.Message.MessageID,
+		Sender: types.Sender{
+			ID:    e.Sender.SenderID.OpenID,
+			IsBot: e.Sender.SenderType == "app",
+		},
+		Text:      e.Text(),
+		Mentions:  mentions,
+		Timestamp: timestamp,
+		Raw:       raw,
+	}
+}
+
+// postText joins the text nodes of a post message.
+// Received posts are {"title": "", "content": [[{"tag": "text", "text": ""}]]};
+// sent posts are wrapped in a locale such as "zh_cn".
+func postText(content string) string {
+	type post struct {
+		Title   string `json:"title"`
+		Content [][]struct {
+			Tag      string `json:"tag"`
+			Text     string `json:"text"`
+			UserName string `json:"user_name"`
+		} `json:"content"`
+	}
+
+	var p post
+	if err := json.Unmarshal([]byte(content), &p); err != nil || p.Content == nil {
+		var localized map[string]post
+		if err := json.Unmarshal([]byte(content), &localized); err != nil {
+			return ""
+		}
+		for _, lp := range localized {
+			p = lp
+			break
+		}
+	}
+
+	lines := make([]string, 0, len(p.Content)+1)
+	if p.Title != "" {
+		lines = append(lines, p.Title)
+	}
+	for _, paragraph := range p.Content {
+		var b strings.Builder
+		for _, node := range paragraph {
+			switch node.Tag {
+			case "text", "a", "md":
+				b.WriteString(node.Text)
+			case "at":
+				b.WriteString("@" + node.UserName)
+			}
+		}
+		lines = append(lines, b.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// NewMessageHandler returns an http.Handler for the event subscription request URL
+// that passes every im.message.receive_v1 event to handler as a types.IncomingMessage.
+// It answers the url_verification challenge, checks the verification token (when
+// non-empty), and verifies and decrypts payloads when encryptKey is set; other
+// events are acknowledged and ignored. Use NewEventDispatcher to handle more events.
+func NewMessageHandler(verificationToken, encryptKey string, handler types.MessageHandler) http.Handler {
+	config := &Config{VerificationToken: verificationToken, EncryptKey: encryptKey}
+	return NewEventDispatcher(config).OnMessageReceive(func(ctx context.Context, event *MessageReceiveEvent) error {
+		return handler(ctx, event.ToIncoming(event.Raw))
+	})
+}
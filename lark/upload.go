@@ -0,0 +1,256 @@
+package lark
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+const (
+	// Upload limits of the im/v1 image and file APIs
+	maxImageSize = 10 << 20
+	maxFileSize  = 30 << 20
+)
+
+// ImageType is the usage of an uploaded image
+type ImageType string
+
+const (
+	ImageTypeMessage ImageType = "message" // Image sent in messages and cards
+	ImageTypeAvatar  ImageType = "avatar"  // Avatar image
+)
+
+// FileType is the type of an uploaded file
+// 参考: https://open.feishu.cn/document/server-docs/im-v1/file/create
+type FileType string
+
+const (
+	FileTypeOpus   FileType = "opus"   // Opus audio, sent as audio messages
+	FileTypeMP4    FileType = "mp4"    // MP4 video, sent as media messages
+	FileTypePDF    FileType = "pdf"    // PDF document
+	FileTypeDoc    FileType = "doc"    // Word document
+	FileTypeXls    FileType = "xls"    // Excel spreadsheet
+	FileTypePpt    FileType = "ppt"    // PowerPoint presentation
+	FileTypeStream FileType = "stream" // Any other file
+)
+
+// FileTypeFromName guesses the upload file type from the file extension
+func FileTypeFromName(name string) FileType {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".opus":
+		return FileTypeOpus
+	case ".mp4":
+		return FileTypeMP4
+	case ".pdf":
+		return FileTypePDF
+	case ".doc", ".docx":
+		return FileTypeDoc
+	case ".xls", ".xlsx":
+		return FileTypeXls
+	case ".ppt", ".pptx":
+		return FileTypePpt
+	default:
+		return FileTypeStream
+	}
+}
+
+// UploadImage uploads an image (JPEG, PNG, WEBP, GIF, TIFF, BMP or ICO, up to 10 MB)
+// and returns its image_key. imageType defaults to ImageTypeMessage.
+// 参考: https://open.feishu.cn/document/server-docs/im-v1/image/create
+func (c *Client) UploadImage(ctx context.Context, image io.Reader, imageType ImageType) (string, error) {
+	if image == nil {
+		return "", fmt.Errorf("image reader cannot be nil")
+	}
+	if c.config.WebhookURL != "" {
+		return "", &types.UnsupportedError{Platform: "lark webhook", Operation: "UploadImage"}
+	}
+	if imageType == "" {
+		imageType = ImageTypeMessage
+	}
+
+	data, err := readUpload(image, maxImageSize)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Data struct {
+			ImageKey string `json:"image_key"`
+		} `json:"data"`
+	}
+	fields := map[string]string{"image_type": string(imageType)}
+	if err := c.upload(ctx, imagesPath, fields, "image", "image", data, &result); err != nil {
+		return "", err
+	}
+	return result.Data.ImageKey, nil
+}
+
+// UploadFile uploads a file (up to 30 MB) and returns its file_key.
+// Audio must be uploaded as FileTypeOpus and video as FileTypeMP4 to be sent
+// as audio and media messages; an empty fileType is guessed from name.
+// 参考: https://open.feishu.cn/document/server-docs/im-v1/file/create
+func (c *Client) UploadFile(ctx context.Context, file io.Reader, fileType FileType, name string) (string, error) {
+	if file == nil {
+		return "", fmt.Errorf("file reader cannot be nil")
+	}
+	if name == "" {
+		return "", fmt.Errorf("file name is required")
+	}
+	if c.config.WebhookURL != "" {
+		return "", &types.UnsupportedError{Platform: "lark webhook", Operation: "UploadFile"}
+	}
+	if fileType == "" {
+		fileType = FileTypeFromName(name)
+	}
+
+	data, err := readUpload(file, maxFileSize)
+	if err != nil {
+		return "", err
+	}
+
+	var result struct {
+		Data struct {
+			FileKey string `json:"file_key"`
+		} `json:"data"`
+	}
+	fields := map[string]string{
+		"file_type": string(fileType),
+		"file_name": name,
+	}
+	if err := c.upload(ctx, filesPath, fields, "file", name, data, &result); err != nil {
+		return "", err
+	}
+	return result.Data.FileKey, nil
+}
+
+// SendImageFromReader uploads an image and sends it as an image message
+func (c *Client) SendImageFromReader(ctx context.Context, image io.Reader, opts *types.SendOptions) ([]types.SendResult, error) {
+	imageKey, err := c.UploadImage(ctx, image, ImageTypeMessage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload image: %w", err)
+	}
+	return c.sendUploaded(ctx, types.MessageTypeImage, map[string]string{"image_key": imageKey}, opts)
+}
+
+// SendFileFromReader uploads a file and sends it as a file message
+func (c *Client) SendFileFromReader(ctx context.Context, file io.Reader, name string, opts *types.SendOptions) ([]types.SendResult, error) {
+	fileKey, err := c.UploadFile(ctx, file, FileTypeFromName(name), name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload file: %w", err)
+	}
+	return c.sendUploaded(ctx, types.MessageTypeFile, map[string]string{"file_key": fileKey}, opts)
+}
+
+// SendAudioFromReader uploads an opus audio file and sends it as an audio message
+func (c *Client) SendAudioFromReader(ctx context.Context, audio io.Reader, name string, opts *types.SendOptions) ([]types.SendResult, error) {
+	fileKey, err := c.UploadFile(ctx, audio, FileTypeOpus, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload audio: %w", err)
+	}
+	return c.sendUploaded(ctx, types.MessageTypeAudio, map[string]string{"file_key": fileKey}, opts)
+}
+
+// SendMediaFromReader uploads an mp4 video and its cover image and sends them as a media message
+func (c *Client) SendMediaFromReader(ctx context.Context, video io.Reader, name string, cover io.Reader, opts *types.SendOptions) ([]types.SendResult, error) {
+	fileKey, err := c.UploadFile(ctx, video, FileTypeMP4, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload video: %w", err)
+	}
+	imageKey, err := c.UploadImage(ctx, cover, ImageTypeMessage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload video cover: %w", err)
+	}
+	return c.sendUploaded(ctx, types.MessageTypeMedia, map[string]string{"file_key": fileKey, "image_key": imageKey}, opts)
+}
+
+// sendUploaded sends a message whose content references uploaded keys
+func (c *Client) sendUploaded(ctx context.Context, msgType types.MessageType, keys map[string]string, opts *types.SendOptions) ([]types.SendResult, error) {
+	content, err := json.Marshal(keys)
+	if err != nil {
+		return nil, err
+	}
+	return c.SendMessageWithResult(ctx, &types.Message{Type: msgType, Content: string(content)}, opts)
+}
+
+// readUpload reads an upload into memory so it can be sent again on retries
+func readUpload(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("upload is empty")
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("upload exceeds %d MB", limit>>20)
+	}
+	return data, nil
+}
+
+// upload posts a multipart form to an upload API, retrying according to the
+// retry policy, and decodes the successful response into result
+func (c *Client) upload(ctx context.Context, path string, fields map[string]string, fileField, fileName string, data []byte, result interface{}) error {
+	return c.retry.Do(ctx, func(ctx context.Context) error {
+		token, err := c.getToken(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get access token: %w", err)
+		}
+
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		for name, value := range fields {
+			if err := writer.WriteField(name, value); err != nil {
+				return err
+			}
+		}
+		part, err := writer.CreateFormFile(fileField, fileName)
+		if err != nil {
+			return err
+		}
+		if _, err := part.Write(data); err != nil {
+			return err
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+path, &body)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		respBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+
+		var apiResp struct {
+			Code int    `json:"code"`
+			Msg  string `json:"msg"`
+		}
+		if err := json.Unmarshal(respBody, &apiResp); err != nil {
+			return err
+		}
+		if apiResp.Code != 0 {
+			return c.apiError(resp, apiResp.Code, apiResp.Msg)
+		}
+
+		return json.Unmarshal(respBody, result)
+	})
+}
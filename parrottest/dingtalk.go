@@ -0,0 +1,199 @@
+package parrottest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/dingtalk"
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+const (
+	// DingTalkAccessToken is the robot access token accepted by DingTalkServer
+	DingTalkAccessToken = "parrottest-token"
+	// DingTalkSecret is the robot signing secret checked by DingTalkServer
+	DingTalkSecret = "SECparrottest"
+	// DingTalkAppKey is the enterprise internal app key accepted by DingTalkServer
+	DingTalkAppKey = "dingparrottest"
+	// DingTalkAppSecret is the enterprise internal app secret accepted by DingTalkServer
+	DingTalkAppSecret = "parrottest-app-secret"
+	// DingTalkAppAccessToken is the app access token issued by DingTalkServer
+	DingTalkAppAccessToken = "parrottest-app-access-token"
+)
+
+// DingTalkServer emulates the DingTalk custom robot webhook and the robot APIs
+// of enterprise internal apps
+type DingTalkServer struct {
+	*Server
+}
+
+// NewDingTalkServer starts a fake robot webhook at /robot/send. The access token
+// is checked, and so is the signature when the request carries one. Replies to
+// outgoing robot messages are accepted at SessionWebhookURL. It also
+// serves the app access token, the robot batch-send and group-send APIs, which
+// return the process query keys "query_1", "query_2", ..., and the matching
+// recall APIs, which fail the keys the server didn't return. Injected faults
+// are answered in the webhook's {"errcode", "errmsg"} format.
+func NewDingTalkServer() *DingTalkServer {
+	s := &DingTalkServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robot/send", s.handleSend)
+	mux.HandleFunc("/robot/sendBySession", s.handleSessionSend)
+	mux.HandleFunc("/v1.0/oauth2/accessToken", s.handleAppToken)
+	mux.HandleFunc("/v1.0/robot/oToMessages/batchSend", s.handleAppSend)
+	mux.HandleFunc("/v1.0/robot/groupMessages/send", s.handleAppSend)
+	mux.HandleFunc("/v1.0/robot/otoMessages/batchRecall", s.handleAppRecall)
+	mux.HandleFunc("/v1.0/robot/groupMessages/recall", s.handleAppRecall)
+
+	rateLimit := Fault{Code: 130101, Message: "send too fast"}
+	s.Server = newServer("dingtalk", rateLimit, writeDingTalkError, mux)
+	return s
+}
+
+// Config returns a signed robot config pointing at the server, with
+// client-side rate limiting disabled and fast retries
+func (s *DingTalkServer) Config() *dingtalk.Config {
+	return &dingtalk.Config{
+		AccessToken: DingTalkAccessToken,
+		Secret:      DingTalkSecret,
+		BaseURL:     s.URL + "/robot/send",
+		RetryPolicy: testRetryPolicy(),
+		RateLimit:   &types.RateLimit{},
+	}
+}
+
+// SessionWebhookURL returns a session webhook URL served by the server, as found
+// in the sessionWebhook field of outgoing robot messages
+func (s *DingTalkServer) SessionWebhookURL() string {
+	return s.URL + "/robot/sendBySession?session=parrottest-session"
+}
+
+// AppConfig returns an enterprise internal app config pointing at the server
+func (s *DingTalkServer) AppConfig() *dingtalk.Config {
+	return &dingtalk.Config{
+		AppKey:      DingTalkAppKey,
+		AppSecret:   DingTalkAppSecret,
+		APIBaseURL:  s.URL,
+		RetryPolicy: testRetryPolicy(),
+		RateLimit:   &types.RateLimit{},
+	}
+}
+
+// writeDingTalkError answers with DingTalk's {"errcode", "errmsg"} body, with HTTP 200 by default
+func writeDingTalkError(w http.ResponseWriter, f *Fault) {
+	status := f.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	retryAfterHeader(w, f)
+	writeJSON(w, status, map[string]interface{}{"errcode": f.Code, "errmsg": f.Message})
+}
+
+func (s *DingTalkServer) handleSend(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("access_token") != DingTalkAccessToken {
+		writeDingTalkError(w, &Fault{Code: 300001, Message: "token is not exist"})
+		return
+	}
+
+	if sign := query.Get("sign"); sign != "" {
+		mac := hmac.New(sha256.New, []byte(DingTalkSecret))
+		fmt.Fprintf(mac, "%s\n%s", query.Get("timestamp"), DingTalkSecret)
+		if base64.StdEncoding.EncodeToString(mac.Sum(nil)) != sign {
+			writeDingTalkError(w, &Fault{Code: 310000, Message: "sign not match"})
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"errcode": 0, "errmsg": "ok"})
+}
+
+func (s *DingTalkServer) handleSessionSend(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("session") == "" {
+		writeDingTalkError(w, &Fault{Code: 300001, Message: "session is not exist"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"errcode": 0, "errmsg": "ok"})
+}
+
+// writeDingTalkAppError answers with the open platform's {"code", "message"} error body
+func writeDingTalkAppError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]interface{}{"code": code, "message": message, "requestid": "parrottest"})
+}
+
+func (s *DingTalkServer) handleAppToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		AppKey    string `json:"appKey"`
+		AppSecret string `json:"appSecret"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	if req.AppKey != DingTalkAppKey || req.AppSecret != DingTalkAppSecret {
+		writeDingTalkAppError(w, http.StatusBadRequest, "invalidClientIdOrSecret", "无效的appKey或appSecret")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"accessToken": DingTalkAppAccessToken,
+		"expireIn":    7200,
+	})
+}
+
+// handleAppSend serves the robot batch-send (one-to-one) and group-send APIs
+func (s *DingTalkServer) handleAppSend(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("x-acs-dingtalk-access-token") != DingTalkAppAccessToken {
+		writeDingTalkAppError(w, http.StatusUnauthorized, "InvalidAuthentication", "不合法的access_token")
+		return
+	}
+
+	var req struct {
+		RobotCode string `json:"robotCode"`
+		MsgKey    string `json:"msgKey"`
+		MsgParam  string `json:"msgParam"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	if req.RobotCode == "" || req.MsgKey == "" || !json.Valid([]byte(req.MsgParam)) {
+		writeDingTalkAppError(w, http.StatusBadRequest, "invalidParameter", "robotCode, msgKey and msgParam are required")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"processQueryKey": fmt.Sprintf("query_%d", s.nextID()),
+	})
+}
+
+func (s *DingTalkServer) handleAppRecall(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("x-acs-dingtalk-access-token") != DingTalkAppAccessToken {
+		writeDingTalkAppError(w, http.StatusUnauthorized, "InvalidAuthentication", "不合法的access_token")
+		return
+	}
+
+	var req struct {
+		RobotCode        string   `json:"robotCode"`
+		ProcessQueryKeys []string `json:"processQueryKeys"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	if req.RobotCode == "" || len(req.ProcessQueryKeys) == 0 {
+		writeDingTalkAppError(w, http.StatusBadRequest, "invalidParameter", "robotCode and processQueryKeys are required")
+		return
+	}
+
+	succeeded := make([]string, 0, len(req.ProcessQueryKeys))
+	failed := make(map[string]string)
+	for _, key := range req.ProcessQueryKeys {
+		if strings.HasPrefix(key, "query_") {
+			succeeded = append(succeeded, key)
+		} else {
+			failed[key] = "processQueryKey not found"
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"successResult": succeeded,
+		"failedResult":  failed,
+	})
+}
@@ -0,0 +1,189 @@
+package parrottest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/lark"
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+const (
+	// LarkAccessToken is the tenant access token issued by LarkServer
+	LarkAccessToken = "t-parrottest"
+	// LarkWebhookPath is the webhook robot path served by LarkServer
+	LarkWebhookPath = "/open-apis/bot/v2/hook/parrottest"
+)
+
+// LarkServer emulates the Lark/Feishu open platform
+type LarkServer struct {
+	*Server
+}
+
+// NewLarkServer starts a fake Lark server serving the tenant access token,
+// message send/update/recall, image/file upload, batch_get_id and webhook
+// robot endpoints. Message IDs are "om_1", "om_2", ..., uploads get the keys
+// "img_N" and "file_N", and looked up users get the open_id "ou_" followed by
+// the mobile or email.
+func NewLarkServer() *LarkServer {
+	s := &LarkServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/open-apis/auth/v3/tenant_access_token/internal", s.handleToken)
+	mux.HandleFunc("/open-apis/im/v1/messages", s.handleSend)
+	mux.HandleFunc("/open-apis/im/v1/messages/", s.handleMessage)
+	mux.HandleFunc("/open-apis/im/v1/images", s.handleUpload("image", "image_key", "img_"))
+	mux.HandleFunc("/open-apis/im/v1/files", s.handleUpload("file", "file_key", "file_"))
+	mux.HandleFunc("/open-apis/contact/v3/users/batch_get_id", s.handleBatchGetID)
+	mux.HandleFunc("/open-apis/bot/v2/hook/", s.handleWebhook)
+
+	// Lark reports rate limiting with HTTP 400 and code 99991400
+	rateLimit := Fault{Status: http.StatusBadRequest, Code: 99991400, Message: "request trigger frequency limit"}
+	s.Server = newServer("lark", rateLimit, writeLarkError, mux)
+	return s
+}
+
+// Config returns an app mode config pointing at the server, with client-side
+// rate limiting disabled and fast retries
+func (s *LarkServer) Config() *lark.Config {
+	return &lark.Config{
+		AppID:       "cli_parrottest",
+		AppSecret:   "parrottest-secret",
+		BaseURL:     s.URL,
+		RetryPolicy: testRetryPolicy(),
+		RateLimit:   &types.RateLimit{},
+	}
+}
+
+// WebhookConfig returns a webhook robot config pointing at the server
+func (s *LarkServer) WebhookConfig() *lark.Config {
+	return &lark.Config{
+		WebhookURL:  s.URL + LarkWebhookPath,
+		RetryPolicy: testRetryPolicy(),
+		RateLimit:   &types.RateLimit{},
+	}
+}
+
+// writeLarkError answers with Lark's {"code", "msg"} error body
+func writeLarkError(w http.ResponseWriter, f *Fault) {
+	status := f.Status
+	if status == 0 {
+		status = http.StatusBadRequest
+	}
+	retryAfterHeader(w, f)
+	writeJSON(w, status, map[string]interface{}{"code": f.Code, "msg": f.Message})
+}
+
+// authorized checks the bearer token issued by the token endpoint
+func (s *LarkServer) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get("Authorization") != "Bearer "+LarkAccessToken {
+		writeLarkError(w, &Fault{Status: http.StatusUnauthorized, Code: 99991663, Message: "Invalid access token for authorization"})
+		return false
+	}
+	return true
+}
+
+func (s *LarkServer) handleToken(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"code":                0,
+		"msg":                 "ok",
+		"tenant_access_token": LarkAccessToken,
+		"expire":              7200,
+	})
+}
+
+func (s *LarkServer) handleSend(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+
+	var req struct {
+		ReceiveID string `json:"receive_id"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	chatID := "oc_p2p_" + req.ReceiveID
+	if r.URL.Query().Get("receive_id_type") == "chat_id" {
+		chatID = req.ReceiveID
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"code": 0,
+		"msg":  "success",
+		"data": map[string]interface{}{
+			"message_id":  fmt.Sprintf("om_%d", s.nextID()),
+			"chat_id":     chatID,
+			"create_time": strconv.FormatInt(time.Now().UnixMilli(), 10),
+		},
+	})
+}
+
+// handleMessage serves update (PUT/PATCH) and recall (DELETE) of a sent message
+func (s *LarkServer) handleMessage(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"code": 0, "msg": "success"})
+}
+
+// handleUpload serves the multipart image and file upload APIs, returning the
+// new key under keyName
+func (s *LarkServer) handleUpload(fileField, keyName, keyPrefix string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(w, r) {
+			return
+		}
+
+		file, _, err := r.FormFile(fileField)
+		if err != nil {
+			writeLarkError(w, &Fault{Code: 234001, Message: "Invalid request param."})
+			return
+		}
+		defer file.Close()
+
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"code": 0,
+			"msg":  "success",
+			"data": map[string]interface{}{keyName: fmt.Sprintf("%s%d", keyPrefix, s.nextID())},
+		})
+	}
+}
+
+func (s *LarkServer) handleBatchGetID(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+
+	var req struct {
+		Mobiles []string `json:"mobiles"`
+		Emails  []string `json:"emails"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	users := make([]map[string]string, 0, len(req.Mobiles)+len(req.Emails))
+	for _, mobile := range req.Mobiles {
+		users = append(users, map[string]string{"user_id": "ou_" + strings.TrimPrefix(mobile, "+"), "mobile": mobile})
+	}
+	for _, email := range req.Emails {
+		users = append(users, map[string]string{"user_id": "ou_" + email, "email": email})
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"code": 0,
+		"msg":  "success",
+		"data": map[string]interface{}{"user_list": users},
+	})
+}
+
+func (s *LarkServer) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"code":          0,
+		"msg":           "success",
+		"data":          map[string]interface{}{},
+		"StatusCode":    0,
+		"StatusMessage": "success",
+	})
+}
@@ -0,0 +1,177 @@
+package parrottest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	imparrot "github.com/JiSuanSiWeiShiXun/parrot"
+	"github.com/JiSuanSiWeiShiXun/parrot/parrottest"
+	"github.com/JiSuanSiWeiShiXun/parrot/telegram"
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+// TestServers tests sending through every fake server
+func TestServers(t *testing.T) {
+	lark := parrottest.NewLarkServer()
+	defer lark.Close()
+	tg := parrottest.NewTelegramServer()
+	defer tg.Close()
+	dingTalk := parrottest.NewDingTalkServer()
+	defer dingTalk.Close()
+	weChat := parrottest.NewWeChatServer()
+	defer weChat.Close()
+	wps := parrottest.NewWPSServer()
+	defer wps.Close()
+
+	tests := []struct {
+		name     string
+		platform string
+		config   types.Config
+		server   *parrottest.Server
+		sendPath string
+	}{
+		{"lark", imparrot.PlatformLark, lark.Config(), lark.Server, "/im/v1/messages"},
+		{"lark webhook", imparrot.PlatformLark, lark.WebhookConfig(), lark.Server, parrottest.LarkWebhookPath},
+		{"telegram", imparrot.PlatformTelegram, tg.Config(), tg.Server, "/sendMessage"},
+		{"dingtalk", imparrot.PlatformDingTalk, dingTalk.Config(), dingTalk.Server, "/robot/send"},
+		{"wechat", imparrot.PlatformWeChat, weChat.Config(), weChat.Server, "/message/send"},
+		{"wpsxz", imparrot.PlatformWPSXZ, wps.Config(), wps.Server, "/v7/messages/create"},
+	}
+
+	msg := &types.Message{Type: types.MessageTypeText, Content: "hello"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.server.Reset()
+
+			client, err := imparrot.NewIMClient(tt.platform, tt.config)
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+			defer client.Close()
+
+			if err := client.SendGroupMessage(context.Background(), "100", msg); err != nil {
+				t.Fatalf("SendGroupMessage() error = %v", err)
+			}
+			if n := len(tt.server.RequestsTo(tt.sendPath)); n != 1 {
+				t.Errorf("Expected 1 send request, got %d", n)
+			}
+
+			// A single rate limit is retried away
+			tt.server.InjectRateLimit(tt.sendPath, 0)
+			if err := client.SendGroupMessage(context.Background(), "100", msg); err != nil {
+				t.Errorf("Expected the rate limited send to be retried, got %v", err)
+			}
+			if n := len(tt.server.RequestsTo(tt.sendPath)); n != 3 {
+				t.Errorf("Expected 3 send requests, got %d", n)
+			}
+
+			// Persistent failures surface as typed errors
+			tt.server.Inject(parrottest.Fault{Path: tt.sendPath, Code: 12345, Message: "boom", Status: 400})
+			err = client.SendGroupMessage(context.Background(), "100", msg)
+			if apiErr, ok := types.AsAPIError(targetError(err)); !ok || apiErr.Code != 12345 {
+				t.Errorf("Expected APIError with code 12345, got %v", err)
+			}
+		})
+	}
+}
+
+// targetError returns the error of the first failed target of a multi-target send
+func targetError(err error) error {
+	var sendErr *types.SendError
+	if errors.As(err, &sendErr) && len(sendErr.FailedTargets) > 0 {
+		return sendErr.FailedTargets[0].Error
+	}
+	return err
+}
+
+// TestLatency tests that injected latency is observed by the client
+func TestLatency(t *testing.T) {
+	server := parrottest.NewTelegramServer()
+	defer server.Close()
+
+	client, err := telegram.NewClient(server.Config(), nil)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	server.InjectLatency("/sendMessage", time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = client.SendPrivateMessage(ctx, "1", &types.Message{Type: types.MessageTypeText, Content: "slow"})
+	if !errors.Is(targetError(err), context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+}
+
+// TestTelegramUpdates tests that pushed updates reach Poll
+func TestTelegramUpdates(t *testing.T) {
+	server := parrottest.NewTelegramServer()
+	defer server.Close()
+
+	client, err := telegram.NewClient(server.Config(), nil)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	server.PushUpdate(&telegram.Update{Message: &telegram.Message{
+		MessageID: 1,
+		Chat:      telegram.Chat{ID: 42, Type: "private"},
+		Text:      "ping",
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var got string
+	err = client.Poll(ctx, telegram.HandleMessages(func(ctx context.Context, msg *types.IncomingMessage) error {
+		got = msg.Text
+		cancel()
+		return nil
+	}))
+	if err != nil {
+		t.Fatalf("Poll() error = %v", err)
+	}
+	if got != "ping" {
+		t.Errorf("Expected to receive ping, got %q", got)
+	}
+}
+
+// TestRecorderClient tests recording, assertions and scripted failures
+func TestRecorderClient(t *testing.T) {
+	var client imparrot.IMParrot = parrottest.NewRecorderClient("lark")
+	recorder := client.(*parrottest.RecorderClient)
+
+	boom := errors.New("boom")
+	recorder.FailTarget("oc_broken", boom)
+
+	msg := &types.Message{Type: types.MessageTypeText, Content: "deploy finished"}
+	err := client.SendMessage(context.Background(), msg, &types.SendOptions{
+		Targets: []types.Target{
+			{ID: "oc_ops", ChatType: types.ChatTypeGroup},
+			{ID: "oc_broken", ChatType: types.ChatTypeGroup},
+			{ID: "ou_alice", ChatType: types.ChatTypePrivate},
+		},
+		Concurrency: 3,
+	})
+
+	var sendErr *types.SendError
+	if !errors.As(err, &sendErr) || sendErr.SuccessCount != 2 || !errors.Is(sendErr.FailedTargets[0].Error, boom) {
+		t.Fatalf("Expected SendError for oc_broken, got %v", err)
+	}
+
+	recorder.AssertSent(t, "oc_ops", 1, "deploy")
+	recorder.AssertSent(t, "ou_alice", 1, "")
+	recorder.AssertNotSent(t, "oc_broken")
+
+	if n := len(recorder.Messages()); n != 2 {
+		t.Errorf("Expected 2 recorded messages, got %d", n)
+	}
+
+	recorder.Reset()
+	if err := client.SendGroupMessage(context.Background(), "oc_broken", msg); err != nil {
+		t.Errorf("Expected Reset to clear failures, got %v", err)
+	}
+}
@@ -0,0 +1,213 @@
+package parrottest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+// TestingT is the subset of testing.TB used by the assertion helpers
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// SentMessage is a message delivered to one target by a RecorderClient
+type SentMessage struct {
+	Message types.Message     // Copy of the sent message
+	Target  types.Target      // Target it was delivered to
+	Options types.SendOptions // Options of the SendMessage call
+	Result  types.SendResult  // Receipt returned to the caller
+}
+
+// RecorderClient is an in-memory types.IMParrot for application unit tests.
+// It records every delivered message instead of calling a platform and can be
+// scripted to fail specific targets. It is safe for concurrent use.
+type RecorderClient struct {
+	platform string
+
+	mu       sync.Mutex
+	sent     []SentMessage
+	failures map[string]error // Keyed by target ID
+	seq      int64
+	closed   bool
+}
+
+// NewRecorderClient creates a recorder reporting the given platform name
+func NewRecorderClient(platform string) *RecorderClient {
+	return &RecorderClient{
+		platform: platform,
+		failures: make(map[string]error),
+	}
+}
+
+// GetPlatformName returns the platform name given to NewRecorderClient
+func (r *RecorderClient) GetPlatformName() string {
+	return r.platform
+}
+
+// SendMessage records the message for every target
+func (r *RecorderClient) SendMessage(ctx context.Context, msg *types.Message, opts *types.SendOptions) error {
+	_, err := r.SendMessageWithResult(ctx, msg, opts)
+	return err
+}
+
+// SendMessageWithResult records the message for every target and returns receipts
+// with message IDs "1", "2", ... Targets scripted with FailTarget fail, reported
+// in a *types.SendError like the platform clients do.
+func (r *RecorderClient) SendMessageWithResult(ctx context.Context, msg *types.Message, opts *types.SendOptions) ([]types.SendResult, error) {
+	if msg == nil || opts == nil {
+		return nil, fmt.Errorf("message and options cannot be nil")
+	}
+
+	if len(opts.Targets) == 0 {
+		return nil, fmt.Errorf("at least one target is required")
+	}
+
+	r.mu.Lock()
+	closed := r.closed
+	r.mu.Unlock()
+	if closed {
+		return nil, fmt.Errorf("client is closed")
+	}
+
+	return types.FanOut(ctx, opts.Targets, opts.Concurrency, func(ctx context.Context, target types.Target) (*types.SendResult, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if err := r.failures[target.ID]; err != nil {
+			return nil, err
+		}
+
+		r.seq++
+		result := types.SendResult{
+			Target:    target,
+			MessageID: strconv.FormatInt(r.seq, 10),
+			ChatID:    target.ID,
+			Timestamp: time.Now(),
+		}
+		r.sent = append(r.sent, SentMessage{
+			Message: *msg,
+			Target:  target,
+			Options: *opts,
+			Result:  result,
+		})
+		return &result, nil
+	})
+}
+
+// SendPrivateMessage records a private message to a user
+func (r *RecorderClient) SendPrivateMessage(ctx context.Context, userID string, msg *types.Message) error {
+	return r.SendMessage(ctx, msg, &types.SendOptions{
+		Targets: []types.Target{{ID: userID, ChatType: types.ChatTypePrivate}},
+	})
+}
+
+// SendGroupMessage records a message to a group
+func (r *RecorderClient) SendGroupMessage(ctx context.Context, groupID string, msg *types.Message) error {
+	return r.SendMessage(ctx, msg, &types.SendOptions{
+		Targets: []types.Target{{ID: groupID, ChatType: types.ChatTypeGroup}},
+	})
+}
+
+// Close marks the client as closed, later sends fail
+func (r *RecorderClient) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return nil
+}
+
+// FailTarget makes every send to targetID fail with err until ClearFailures.
+// A nil err removes the failure.
+func (r *RecorderClient) FailTarget(targetID string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err == nil {
+		delete(r.failures, targetID)
+		return
+	}
+	r.failures[targetID] = err
+}
+
+// ClearFailures removes every failure scripted with FailTarget
+func (r *RecorderClient) ClearFailures() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures = make(map[string]error)
+}
+
+// Reset forgets the recorded messages and scripted failures
+func (r *RecorderClient) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = nil
+	r.failures = make(map[string]error)
+}
+
+// Messages returns a copy of every delivered message, in send order
+func (r *RecorderClient) Messages() []SentMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]SentMessage(nil), r.sent...)
+}
+
+// MessagesTo returns the messages delivered to targetID
+func (r *RecorderClient) MessagesTo(targetID string) []SentMessage {
+	return r.Find(func(m SentMessage) bool {
+		return m.Target.ID == targetID
+	})
+}
+
+// Find returns the delivered messages that match
+func (r *RecorderClient) Find(match func(SentMessage) bool) []SentMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	found := make([]SentMessage, 0)
+	for _, m := range r.sent {
+		if match(m) {
+			found = append(found, m)
+		}
+	}
+	return found
+}
+
+// AssertSent checks that exactly count messages whose content contains substr
+// (any content when empty) were delivered to targetID
+func (r *RecorderClient) AssertSent(t TestingT, targetID string, count int, substr string) bool {
+	t.Helper()
+
+	matched := r.Find(func(m SentMessage) bool {
+		return m.Target.ID == targetID && strings.Contains(m.Message.Content, substr)
+	})
+	if len(matched) != count {
+		if substr != "" {
+			t.Errorf("expected %d message(s) to %s containing %q, got %d", count, targetID, substr, len(matched))
+		} else {
+			t.Errorf("expected %d message(s) to %s, got %d", count, targetID, len(matched))
+		}
+		return false
+	}
+	return true
+}
+
+// AssertNotSent checks that nothing was delivered to targetID
+func (r *RecorderClient) AssertNotSent(t TestingT, targetID string) bool {
+	t.Helper()
+
+	if matched := r.MessagesTo(targetID); len(matched) > 0 {
+		t.Errorf("expected no message to %s, got %d", targetID, len(matched))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,232 @@
+// Package parrottest provides in-process fake IM servers for hermetic tests.
+//
+// Each server emulates the token, send, user lookup and webhook endpoints of a
+// platform on an httptest.Server, records every request it receives, and can
+// inject errors, latency and rate limits. Config returns a client config that
+// points at the server:
+//
+//	server := parrottest.NewLarkServer()
+//	defer server.Close()
+//
+//	client, _ := imparrot.NewIMClient(imparrot.PlatformLark, server.Config())
+//	server.InjectRateLimit("/im/v1/messages", time.Second)
+package parrottest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+// Request is a request received by a fake server
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+	Time   time.Time
+}
+
+// Fault is a failure injected into the responses of a fake server
+type Fault struct {
+	Path       string        // Substring of the request path the fault applies to, empty for every request
+	Latency    time.Duration // Delay before answering (the request still succeeds unless Code is set)
+	Status     int           // HTTP status of the error response (default: the platform's usual status)
+	Code       int           // Platform error code, 0 to only add latency
+	Message    string        // Platform error message
+	RetryAfter time.Duration // Retry hint, sent where the platform supports one
+	Times      int           // Number of requests the fault applies to, 0 for all of them
+}
+
+// errorWriter writes a platform-specific error response for a fault
+type errorWriter func(w http.ResponseWriter, f *Fault)
+
+// Server is a fake platform server. It is safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	platform   string
+	rateLimit  Fault // Template of the platform's rate limit error
+	writeError errorWriter
+
+	mu       sync.Mutex
+	requests []Request
+	faults   []*Fault
+	seq      int64
+}
+
+// newServer starts a fake server answering with handler once faults are applied
+func newServer(platform string, rateLimit Fault, writeError errorWriter, handler http.Handler) *Server {
+	s := &Server{
+		platform:   platform,
+		rateLimit:  rateLimit,
+		writeError: writeError,
+	}
+	s.Server = httptest.NewServer(s.wrap(handler))
+	return s
+}
+
+// wrap records the request and applies the matching faults before calling handler
+func (s *Server) wrap(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Query:  r.URL.Query(),
+			Header: r.Header.Clone(),
+			Body:   body,
+			Time:   time.Now(),
+		})
+		fault := s.takeFault(r.URL.Path)
+		s.mu.Unlock()
+
+		if fault != nil {
+			if fault.Latency > 0 {
+				select {
+				case <-time.After(fault.Latency):
+				case <-r.Context().Done():
+					return
+				}
+			}
+			if fault.Code != 0 {
+				s.writeError(w, fault)
+				return
+			}
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// takeFault returns the first fault matching path, consuming one of its uses.
+// Must be called with s.mu held.
+func (s *Server) takeFault(path string) *Fault {
+	for i, f := range s.faults {
+		if f.Path != "" && !strings.Contains(path, f.Path) {
+			continue
+		}
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+		copied := *f
+		return &copied
+	}
+	return nil
+}
+
+// nextID returns a new sequence number for message IDs
+func (s *Server) nextID() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	return s.seq
+}
+
+// Platform returns the name of the emulated platform
+func (s *Server) Platform() string {
+	return s.platform
+}
+
+// Inject adds a fault. Faults are matched in the order they were added.
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+// InjectError makes the next times requests to path fail with the platform error code
+func (s *Server) InjectError(path string, code int, message string, times int) {
+	s.Inject(Fault{Path: path, Code: code, Message: message, Times: times})
+}
+
+// InjectLatency delays every request to path by d
+func (s *Server) InjectLatency(path string, d time.Duration) {
+	s.Inject(Fault{Path: path, Latency: d})
+}
+
+// InjectRateLimit makes the next request to path fail with the platform's
+// rate limit error, carrying retryAfter where the platform reports one
+func (s *Server) InjectRateLimit(path string, retryAfter time.Duration) {
+	f := s.rateLimit
+	f.Path = path
+	f.RetryAfter = retryAfter
+	f.Times = 1
+	s.Inject(f)
+}
+
+// ClearFaults removes every injected fault
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// Requests returns a copy of every request received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// RequestsTo returns the requests whose path contains path
+func (s *Server) RequestsTo(path string) []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matched := make([]Request, 0)
+	for _, r := range s.requests {
+		if strings.Contains(r.Path, path) {
+			matched = append(matched, r)
+		}
+	}
+	return matched
+}
+
+// Reset forgets the recorded requests and injected faults
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+	s.faults = nil
+}
+
+// testRetryPolicy retries like the default policy without slowing tests down
+func testRetryPolicy() *types.RetryPolicy {
+	return &types.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+		Multiplier:     2,
+	}
+}
+
+// writeJSON writes v as a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// retryAfterHeader sets the Retry-After header for a fault that carries a hint
+func retryAfterHeader(w http.ResponseWriter, f *Fault) {
+	if f.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(f.RetryAfter/time.Second)))
+	}
+}
@@ -0,0 +1,218 @@
+package parrottest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/telegram"
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+// TelegramBotToken is the bot token accepted by TelegramServer
+const TelegramBotToken = "123456:parrottest"
+
+// maxPollWait caps how long getUpdates waits for pushed updates, whatever timeout the client asks for
+const maxPollWait = 5 * time.Second
+
+// TelegramServer emulates the Telegram Bot API
+type TelegramServer struct {
+	*Server
+
+	mu      sync.Mutex
+	updates []json.RawMessage
+	lastID  int64
+	notify  chan struct{} // Closed and replaced when an update is pushed
+}
+
+// NewTelegramServer starts a fake Bot API server. Every send* method returns a
+// message with IDs 1, 2, ... (sendMediaGroup one per item); getUpdates returns the updates queued with PushUpdate;
+// other methods such as editMessageText and deleteMessage return true.
+func NewTelegramServer() *TelegramServer {
+	s := &TelegramServer{notify: make(chan struct{})}
+	rateLimit := Fault{Code: http.StatusTooManyRequests, Message: "Too Many Requests: retry after 1"}
+	s.Server = newServer("telegram", rateLimit, writeTelegramError, http.HandlerFunc(s.handle))
+	return s
+}
+
+// Config returns a config pointing at the server, with client-side rate
+// limiting disabled and fast retries
+func (s *TelegramServer) Config() *telegram.Config {
+	return &telegram.Config{
+		BotToken:    TelegramBotToken,
+		BaseURL:     s.URL + "/bot",
+		RetryPolicy: testRetryPolicy(),
+		RateLimit:   &types.RateLimit{},
+		PollTimeout: time.Second,
+	}
+}
+
+// PushUpdate queues an update for getUpdates. A zero UpdateID is assigned automatically.
+func (s *TelegramServer) PushUpdate(update *telegram.Update) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if update.UpdateID == 0 {
+		update.UpdateID = s.lastID + 1
+	}
+	if update.UpdateID > s.lastID {
+		s.lastID = update.UpdateID
+	}
+
+	raw, _ := json.Marshal(update)
+	s.updates = append(s.updates, raw)
+
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+// writeTelegramError answers with the Bot API error body; the HTTP status mirrors error_code
+func writeTelegramError(w http.ResponseWriter, f *Fault) {
+	status := f.Status
+	if status == 0 {
+		status = f.Code
+	}
+	if status < 400 || status > 599 {
+		status = http.StatusBadRequest
+	}
+
+	body := map[string]interface{}{
+		"ok":          false,
+		"error_code":  f.Code,
+		"description": f.Message,
+	}
+	if f.RetryAfter > 0 {
+		body["parameters"] = map[string]interface{}{"retry_after": int(f.RetryAfter / time.Second)}
+	}
+	writeJSON(w, status, body)
+}
+
+func (s *TelegramServer) handle(w http.ResponseWriter, r *http.Request) {
+	// Paths look like /bot<token>/<method>
+	path := strings.TrimPrefix(r.URL.Path, "/bot")
+	token, method, ok := strings.Cut(path, "/")
+	if !ok || path == r.URL.Path {
+		writeTelegramError(w, &Fault{Code: http.StatusNotFound, Message: "Not Found"})
+		return
+	}
+	if token != TelegramBotToken {
+		writeTelegramError(w, &Fault{Code: http.StatusUnauthorized, Message: "Unauthorized"})
+		return
+	}
+
+	params := requestParams(r)
+
+	switch {
+	case method == "getMe":
+		s.writeResult(w, map[string]interface{}{"id": 123456, "is_bot": true, "first_name": "parrottest", "username": "parrottest_bot"})
+	case method == "getUpdates":
+		s.handleGetUpdates(w, r, params)
+	case method == "sendMediaGroup":
+		var media []interface{}
+		_ = json.Unmarshal([]byte(paramString(params["media"])), &media)
+		messages := make([]interface{}, 0, len(media))
+		for range media {
+			messages = append(messages, s.message(params))
+		}
+		s.writeResult(w, messages)
+	case strings.HasPrefix(method, "send"):
+		s.writeResult(w, s.message(params))
+	default:
+		s.writeResult(w, true)
+	}
+}
+
+// message builds the Message returned by a send* method
+func (s *TelegramServer) message(params map[string]interface{}) map[string]interface{} {
+	chatID, _ := strconv.ParseInt(paramString(params["chat_id"]), 10, 64)
+	return map[string]interface{}{
+		"message_id": s.nextID(),
+		"date":       time.Now().Unix(),
+		"chat":       map[string]interface{}{"id": chatID},
+	}
+}
+
+func (s *TelegramServer) handleGetUpdates(w http.ResponseWriter, r *http.Request, params map[string]interface{}) {
+	offset, _ := strconv.ParseInt(paramString(params["offset"]), 10, 64)
+	timeout, _ := strconv.Atoi(paramString(params["timeout"]))
+
+	wait := time.Duration(timeout) * time.Second
+	if wait > maxPollWait {
+		wait = maxPollWait
+	}
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+
+	for {
+		s.mu.Lock()
+		pending := make([]json.RawMessage, 0, len(s.updates))
+		kept := s.updates[:0]
+		for _, raw := range s.updates {
+			var u struct {
+				UpdateID int64 `json:"update_id"`
+			}
+			_ = json.Unmarshal(raw, &u)
+			// Updates below the offset are confirmed and forgotten
+			if u.UpdateID < offset {
+				continue
+			}
+			kept = append(kept, raw)
+			pending = append(pending, raw)
+		}
+		s.updates = kept
+		notify := s.notify
+		s.mu.Unlock()
+
+		if len(pending) > 0 || wait <= 0 {
+			s.writeResult(w, pending)
+			return
+		}
+
+		select {
+		case <-notify:
+		case <-deadline.C:
+			wait = 0
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (s *TelegramServer) writeResult(w http.ResponseWriter, result interface{}) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"ok": true, "result": result})
+}
+
+// requestParams reads the method parameters from a JSON, form or multipart body
+func requestParams(r *http.Request) map[string]interface{} {
+	params := make(map[string]interface{})
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		_ = json.NewDecoder(r.Body).Decode(&params)
+		return params
+	}
+
+	_ = r.ParseMultipartForm(32 << 20)
+	for key, values := range r.Form {
+		if len(values) > 0 {
+			params[key] = values[0]
+		}
+	}
+	return params
+}
+
+// paramString formats a JSON or form parameter as a string
+func paramString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
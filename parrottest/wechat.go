@@ -0,0 +1,226 @@
+package parrottest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+	"github.com/JiSuanSiWeiShiXun/parrot/wechat"
+)
+
+const (
+	// WeChatCorpID is the corp ID accepted by WeChatServer
+	WeChatCorpID = "wwparrottest"
+	// WeChatCorpSecret is the app secret accepted by WeChatServer
+	WeChatCorpSecret = "parrottest-secret"
+	// WeChatAccessToken is the access token issued by WeChatServer
+	WeChatAccessToken = "parrottest-access-token"
+)
+
+// WeChatServer emulates the WeChat Work (企业微信) server API
+type WeChatServer struct {
+	*Server
+
+	mu    sync.Mutex
+	chats map[string]*wechat.AppChat
+}
+
+// NewWeChatServer starts a fake WeChat Work server serving gettoken, message
+// send/recall, appchat create/update/get/send and user/getuserid. Message IDs
+// are "msg_1", "msg_2", ..., group chats created without an ID get "chat_N",
+// and a user looked up by mobile gets the mobile as user ID.
+func NewWeChatServer() *WeChatServer {
+	s := &WeChatServer{chats: make(map[string]*wechat.AppChat)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cgi-bin/gettoken", s.handleToken)
+	mux.HandleFunc("/cgi-bin/message/send", s.handleSend)
+	mux.HandleFunc("/cgi-bin/message/recall", s.handleOK)
+	mux.HandleFunc("/cgi-bin/user/getuserid", s.handleGetUserID)
+	mux.HandleFunc("/cgi-bin/appchat/create", s.handleAppChatCreate)
+	mux.HandleFunc("/cgi-bin/appchat/update", s.handleAppChatUpdate)
+	mux.HandleFunc("/cgi-bin/appchat/get", s.handleAppChatGet)
+	mux.HandleFunc("/cgi-bin/appchat/send", s.handleAppChatSend)
+
+	rateLimit := Fault{Code: 45009, Message: "api freq out of limit"}
+	s.Server = newServer("wechat", rateLimit, writeWeChatError, mux)
+	return s
+}
+
+// Config returns a config pointing at the server, with client-side rate
+// limiting disabled and fast retries
+func (s *WeChatServer) Config() *wechat.Config {
+	return &wechat.Config{
+		CorpID:      WeChatCorpID,
+		CorpSecret:  WeChatCorpSecret,
+		AgentID:     1000001,
+		BaseURL:     s.URL,
+		RetryPolicy: testRetryPolicy(),
+		RateLimit:   &types.RateLimit{},
+	}
+}
+
+// writeWeChatError answers with WeChat's {"errcode", "errmsg"} body, with HTTP 200 by default
+func writeWeChatError(w http.ResponseWriter, f *Fault) {
+	status := f.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	retryAfterHeader(w, f)
+	writeJSON(w, status, map[string]interface{}{"errcode": f.Code, "errmsg": f.Message})
+}
+
+// authorized checks the access_token issued by gettoken
+func (s *WeChatServer) authorized(w http.ResponseWriter, r *http.Request) bool {
+	if r.URL.Query().Get("access_token") != WeChatAccessToken {
+		writeWeChatError(w, &Fault{Code: 40014, Message: "invalid access_token"})
+		return false
+	}
+	return true
+}
+
+func (s *WeChatServer) handleToken(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	switch {
+	case query.Get("corpid") != WeChatCorpID:
+		writeWeChatError(w, &Fault{Code: 40013, Message: "invalid corpid"})
+	case query.Get("corpsecret") != WeChatCorpSecret:
+		writeWeChatError(w, &Fault{Code: 40001, Message: "invalid credential"})
+	default:
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"errcode":      0,
+			"errmsg":       "ok",
+			"access_token": WeChatAccessToken,
+			"expires_in":   7200,
+		})
+	}
+}
+
+func (s *WeChatServer) handleSend(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"errcode": 0,
+		"errmsg":  "ok",
+		"msgid":   fmt.Sprintf("msg_%d", s.nextID()),
+	})
+}
+
+func (s *WeChatServer) handleOK(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"errcode": 0, "errmsg": "ok"})
+}
+
+func (s *WeChatServer) handleGetUserID(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+
+	var req struct {
+		Mobile string `json:"mobile"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"errcode": 0, "errmsg": "ok", "userid": req.Mobile})
+}
+
+func (s *WeChatServer) handleAppChatCreate(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+
+	var chat wechat.AppChat
+	_ = json.NewDecoder(r.Body).Decode(&chat)
+	if len(chat.UserList) < 2 {
+		writeWeChatError(w, &Fault{Code: 86216, Message: "invalid userlist"})
+		return
+	}
+	if chat.ChatID == "" {
+		chat.ChatID = fmt.Sprintf("chat_%d", s.nextID())
+	}
+
+	s.mu.Lock()
+	s.chats[chat.ChatID] = &chat
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"errcode": 0, "errmsg": "ok", "chatid": chat.ChatID})
+}
+
+func (s *WeChatServer) handleAppChatUpdate(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+
+	var req struct {
+		ChatID      string   `json:"chatid"`
+		Name        string   `json:"name"`
+		Owner       string   `json:"owner"`
+		AddUserList []string `json:"add_user_list"`
+		DelUserList []string `json:"del_user_list"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chat, ok := s.chats[req.ChatID]
+	if !ok {
+		writeWeChatError(w, &Fault{Code: 86003, Message: "chat not exist"})
+		return
+	}
+	if req.Name != "" {
+		chat.Name = req.Name
+	}
+	if req.Owner != "" {
+		chat.Owner = req.Owner
+	}
+	chat.UserList = append(chat.UserList, req.AddUserList...)
+	for _, removed := range req.DelUserList {
+		for i, user := range chat.UserList {
+			if user == removed {
+				chat.UserList = append(chat.UserList[:i], chat.UserList[i+1:]...)
+				break
+			}
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"errcode": 0, "errmsg": "ok"})
+}
+
+func (s *WeChatServer) handleAppChatGet(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chat, ok := s.chats[r.URL.Query().Get("chatid")]
+	if !ok {
+		writeWeChatError(w, &Fault{Code: 86003, Message: "chat not exist"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"errcode": 0, "errmsg": "ok", "chat_info": chat})
+}
+
+// handleAppChatSend accepts messages to any chat ID, so tests can send to chats
+// they didn't create; like the real API it returns no message ID
+func (s *WeChatServer) handleAppChatSend(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(w, r) {
+		return
+	}
+
+	var req struct {
+		ChatID string `json:"chatid"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	if req.ChatID == "" {
+		writeWeChatError(w, &Fault{Code: 86003, Message: "chat not exist"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"errcode": 0, "errmsg": "ok"})
+}
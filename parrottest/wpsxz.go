@@ -0,0 +1,88 @@
+package parrottest
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+	"github.com/JiSuanSiWeiShiXun/parrot/wpsxz"
+)
+
+const (
+	// WPSAppID is the client_id accepted by WPSServer
+	WPSAppID = "parrottest-app"
+	// WPSAppSecret is the client_secret accepted by WPSServer
+	WPSAppSecret = "parrottest-secret"
+	// WPSAccessToken is the access token issued by WPSServer
+	WPSAccessToken = "parrottest-access-token"
+)
+
+// WPSServer emulates the WPS Xiezuo (WPS 协作) open platform API
+type WPSServer struct {
+	*Server
+}
+
+// NewWPSServer starts a fake WPS Xiezuo server serving oauth2/token and
+// messages/create. Message IDs are "msg_1", "msg_2", ...
+func NewWPSServer() *WPSServer {
+	s := &WPSServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth2/token", s.handleToken)
+	mux.HandleFunc("/v7/messages/create", s.handleSend)
+
+	rateLimit := Fault{Status: http.StatusTooManyRequests, Code: 429, Message: "too many requests"}
+	s.Server = newServer("wpsxz", rateLimit, writeWPSError, mux)
+	return s
+}
+
+// Config returns a config pointing at the server, with client-side rate
+// limiting disabled and fast retries
+func (s *WPSServer) Config() *wpsxz.Config {
+	return &wpsxz.Config{
+		AppID:       WPSAppID,
+		AppSecret:   WPSAppSecret,
+		BaseURL:     s.URL,
+		RetryPolicy: testRetryPolicy(),
+		RateLimit:   &types.RateLimit{},
+	}
+}
+
+// writeWPSError answers with WPS's {"code", "msg"} body. WPS reports failures
+// through the HTTP status, 400 by default.
+func writeWPSError(w http.ResponseWriter, f *Fault) {
+	status := f.Status
+	if status == 0 {
+		status = http.StatusBadRequest
+	}
+	retryAfterHeader(w, f)
+	writeJSON(w, status, map[string]interface{}{"code": f.Code, "msg": f.Message})
+}
+
+func (s *WPSServer) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("grant_type") != "client_credentials" {
+		writeWPSError(w, &Fault{Code: 400, Message: "invalid grant_type"})
+		return
+	}
+	if r.PostForm.Get("client_id") != WPSAppID || r.PostForm.Get("client_secret") != WPSAppSecret {
+		writeWPSError(w, &Fault{Status: http.StatusUnauthorized, Code: 401, Message: "invalid client"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"code":         0,
+		"access_token": WPSAccessToken,
+		"expires_in":   7200,
+	})
+}
+
+func (s *WPSServer) handleSend(w http.ResponseWriter, r *http.Request) {
+	if strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ") != WPSAccessToken {
+		writeWPSError(w, &Fault{Status: http.StatusUnauthorized, Code: 401, Message: "invalid access token"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"code": 0,
+		"msg":  "success",
+		"data": map[string]interface{}{"message_id": fmt.Sprintf("msg_%d", s.nextID())},
+	})
+}
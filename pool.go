@@ -19,6 +19,7 @@ type ClientPool struct {
 	maxIdle   time.Duration
 	lastUsed  map[string]time.Time
 	httpPool  *http.Client // Shared HTTP client for all connections
+	defaults  *clientDefaults
 	closeChan chan struct{}
 	wg        sync.WaitGroup
 }
@@ -39,6 +40,14 @@ type PoolConfig struct {
 	MaxIdleConns int
 	// MaxIdleConnsPerHost controls the maximum idle connections per host
 	MaxIdleConnsPerHost int
+
+	// RetryPolicy is applied to clients whose config doesn't set its own
+	// Default: nil (each platform uses types.DefaultRetryPolicy())
+	RetryPolicy *types.RetryPolicy
+
+	// Concurrency is the fan-out concurrency for clients whose config doesn't set one
+	// Default: 0 (each client sends to one target at a time)
+	Concurrency int
 }
 
 // DefaultPoolConfig returns a pool config with sensible defaults
@@ -74,6 +83,7 @@ func NewClientPool(config *PoolConfig) *ClientPool {
 		lastUsed:  make(map[string]time.Time),
 		maxIdle:   config.MaxIdleTime,
 		httpPool:  httpClient,
+		defaults:  &clientDefaults{retryPolicy: config.RetryPolicy, concurrency: config.Concurrency},
 		closeChan: make(chan struct{}),
 	}
 
@@ -239,5 +249,5 @@ func (p *ClientPool) createClient(platform string, config types.Config) (types.I
 	}
 
 	// Use shared HTTP client for all platforms
-	return createClientWithHTTP(platform, config, p.httpPool)
+	return createClientWithHTTP(platform, config, p.httpPool, p.defaults)
 }
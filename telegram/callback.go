@@ -0,0 +1,140 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+// CallbackQuery is sent when a user presses a callback button of an inline keyboard.
+// 参考: https://core.telegram.org/bots/api#callbackquery
+type CallbackQuery struct {
+	ID              string   `json:"id"`
+	From            User     `json:"from"`
+	Message         *Message `json:"message,omitempty"`           // Message with the button, if it was sent by the bot
+	InlineMessageID string   `json:"inline_message_id,omitempty"` // Set instead of Message for inline mode messages
+	ChatInstance    string   `json:"chat_instance"`
+	Data            string   `json:"data,omitempty"` // callback_data of the pressed button
+}
+
+// CallbackResponse is the answer to a callback query
+type CallbackResponse struct {
+	Text      string // Optional: notification shown to the user, 0-200 characters
+	ShowAlert bool   // Show an alert instead of a notification at the top of the chat
+	URL       string // Optional: URL opened by the user's client
+	CacheTime int    // Optional: seconds the answer may be cached by the client
+
+	Edit        *types.Message        // Optional: new text of the originating message (editMessageText)
+	ReplyMarkup *InlineKeyboardMarkup // Optional: new keyboard of the originating message, NewInlineKeyboard() removes it
+}
+
+// CallbackQueryHandler handles a callback query. A nil response answers the query
+// without a notification and leaves the message as it is.
+type CallbackQueryHandler func(ctx context.Context, query *CallbackQuery) (*CallbackResponse, error)
+
+// HandleCallbackQueries returns an UpdateHandler passing callback queries to handler,
+// other updates are ignored. The query is answered with answerCallbackQuery (which
+// stops the button's loading animation) and the originating message is edited as
+// the response asks. Use MultiHandler to combine it with HandleMessages.
+func (c *Client) HandleCallbackQueries(handler CallbackQueryHandler) UpdateHandler {
+	return func(ctx context.Context, update *Update) error {
+		query := update.CallbackQuery
+		if query == nil {
+			return nil
+		}
+
+		resp, err := handler(ctx, query)
+		if err != nil {
+			return err
+		}
+		if resp == nil {
+			resp = &CallbackResponse{}
+		}
+
+		if err := c.AnswerCallbackQuery(ctx, query.ID, resp); err != nil {
+			return fmt.Errorf("failed to answer callback query: %w", err)
+		}
+
+		if resp.Edit == nil && resp.ReplyMarkup == nil {
+			return nil
+		}
+		if err := c.editCallbackMessage(ctx, query, resp); err != nil {
+			return fmt.Errorf("failed to edit message: %w", err)
+		}
+		return nil
+	}
+}
+
+// AnswerCallbackQuery answers a callback query with the notification of resp (answerCallbackQuery)
+func (c *Client) AnswerCallbackQuery(ctx context.Context, queryID string, resp *CallbackResponse) error {
+	reqBody := map[string]interface{}{
+		"callback_query_id": queryID,
+	}
+	if resp != nil {
+		if resp.Text != "" {
+			reqBody["text"] = resp.Text
+		}
+		if resp.ShowAlert {
+			reqBody["show_alert"] = true
+		}
+		if resp.URL != "" {
+			reqBody["url"] = resp.URL
+		}
+		if resp.CacheTime > 0 {
+			reqBody["cache_time"] = resp.CacheTime
+		}
+	}
+
+	_, err := c.callMethod(ctx, "answerCallbackQuery", reqBody)
+	return err
+}
+
+// editCallbackMessage edits the message a callback query came from
+func (c *Client) editCallbackMessage(ctx context.Context, query *CallbackQuery, resp *CallbackResponse) error {
+	reqBody := make(map[string]interface{})
+	switch {
+	case query.Message != nil:
+		reqBody["chat_id"] = strconv.FormatInt(query.Message.Chat.ID, 10)
+		reqBody["message_id"] = query.Message.MessageID
+	case query.InlineMessageID != "":
+		reqBody["inline_message_id"] = query.InlineMessageID
+	default:
+		return fmt.Errorf("callback query %s has no message to edit", query.ID)
+	}
+
+	// Only the keyboard changes
+	if resp.Edit == nil {
+		reqBody["reply_markup"] = resp.ReplyMarkup
+		_, err := c.callMethod(ctx, "editMessageReplyMarkup", reqBody)
+		return err
+	}
+
+	reqBody["text"] = resp.Edit.Content
+	if resp.Edit.Type == types.MessageTypeMarkdown {
+		reqBody["text"], reqBody["parse_mode"] = markdownText(resp.Edit, c.config.ParseMode)
+	}
+	for k, v := range resp.Edit.Data {
+		reqBody[k] = v
+	}
+	if resp.ReplyMarkup != nil {
+		reqBody["reply_markup"] = resp.ReplyMarkup
+	}
+
+	_, err := c.callMethod(ctx, "editMessageText", reqBody)
+	return err
+}
+
+// MultiHandler returns an UpdateHandler calling every handler in order, stopping
+// at the first error, e.g. to handle both messages and callback queries
+func MultiHandler(handlers ...UpdateHandler) UpdateHandler {
+	return func(ctx context.Context, update *Update) error {
+		for _, handler := range handlers {
+			if err := handler(ctx, update); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
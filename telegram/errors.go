@@ -0,0 +1,61 @@
+package telegram
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+// invalidTargetDescriptions are Bad Request descriptions that mean the chat can't be reached
+var invalidTargetDescriptions = []string{
+	"chat not found",
+	"user not found",
+	"peer_id_invalid",
+	"bot was kicked",
+	"bot is not a member",
+	"have no rights to send",
+}
+
+// responseParams carries hints returned with failed requests
+type responseParams struct {
+	RetryAfter      int   `json:"retry_after"`        // Seconds to wait when flood control is exceeded
+	MigrateToChatID int64 `json:"migrate_to_chat_id"` // The group was upgraded to this supergroup
+}
+
+// newAPIError builds a typed error from a Bot API response.
+// Telegram mirrors the HTTP status in error_code, so classification is by code and description.
+func newAPIError(resp *http.Response, errorCode int, description string, params responseParams) *types.APIError {
+	var kind types.ErrorKind
+	switch {
+	case errorCode == http.StatusTooManyRequests:
+		kind = types.ErrorKindRateLimited
+	case errorCode == http.StatusUnauthorized, errorCode == http.StatusNotFound:
+		// An invalid bot token yields 401 Unauthorized or 404 Not Found
+		kind = types.ErrorKindAuth
+	case errorCode == http.StatusForbidden:
+		// Bot was blocked by the user or removed from the group
+		kind = types.ErrorKindInvalidTarget
+	case errorCode >= http.StatusInternalServerError || resp.StatusCode >= http.StatusInternalServerError:
+		kind = types.ErrorKindServer
+	case errorCode == http.StatusBadRequest:
+		kind = types.ErrorKindInvalidRequest
+		lower := strings.ToLower(description)
+		for _, d := range invalidTargetDescriptions {
+			if strings.Contains(lower, d) {
+				kind = types.ErrorKindInvalidTarget
+				break
+			}
+		}
+	}
+
+	return &types.APIError{
+		Platform:   "telegram",
+		HTTPStatus: resp.StatusCode,
+		Code:       errorCode,
+		Message:    description,
+		Kind:       kind,
+		RetryAfter: time.Duration(params.RetryAfter) * time.Second,
+	}
+}
@@ -0,0 +1,101 @@
+package telegram
+
+// InlineKeyboardButton is a button of an inline keyboard. Exactly one of
+// CallbackData and URL should be set.
+type InlineKeyboardButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data,omitempty"` // Sent back in a callback query when pressed, 1-64 bytes
+	URL          string `json:"url,omitempty"`           // Opened when pressed
+}
+
+// CallbackButton creates a button that sends data back in a callback query
+func CallbackButton(text, data string) InlineKeyboardButton {
+	return InlineKeyboardButton{Text: text, CallbackData: data}
+}
+
+// URLButton creates a button that opens url
+func URLButton(text, url string) InlineKeyboardButton {
+	return InlineKeyboardButton{Text: text, URL: url}
+}
+
+// InlineKeyboardMarkup is a keyboard attached to a message. Set it as
+// Message.Data["reply_markup"] or CallbackResponse.ReplyMarkup.
+// 参考: https://core.telegram.org/bots/api#inlinekeyboardmarkup
+type InlineKeyboardMarkup struct {
+	InlineKeyboard [][]InlineKeyboardButton `json:"inline_keyboard"`
+}
+
+// NewInlineKeyboard creates an inline keyboard with the given rows.
+// A keyboard without rows removes the buttons of an edited message.
+func NewInlineKeyboard(rows ...[]InlineKeyboardButton) *InlineKeyboardMarkup {
+	return &InlineKeyboardMarkup{InlineKeyboard: append([][]InlineKeyboardButton{}, rows...)}
+}
+
+// Row appends a row of buttons
+func (k *InlineKeyboardMarkup) Row(buttons ...InlineKeyboardButton) *InlineKeyboardMarkup {
+	k.InlineKeyboard = append(k.InlineKeyboard, buttons)
+	return k
+}
+
+// KeyboardButton is a button of a reply keyboard; its text is sent as a message when pressed
+type KeyboardButton struct {
+	Text            string `json:"text"`
+	RequestContact  bool   `json:"request_contact,omitempty"`  // Send the user's phone number (private chats only)
+	RequestLocation bool   `json:"request_location,omitempty"` // Send the user's location (private chats only)
+}
+
+// ReplyKeyboardMarkup replaces the user's keyboard with custom buttons.
+// Set it as Message.Data["reply_markup"].
+// 参考: https://core.telegram.org/bots/api#replykeyboardmarkup
+type ReplyKeyboardMarkup struct {
+	Keyboard              [][]KeyboardButton `json:"keyboard"`
+	IsPersistent          bool               `json:"is_persistent,omitempty"`
+	ResizeKeyboard        bool               `json:"resize_keyboard,omitempty"`
+	OneTimeKeyboard       bool               `json:"one_time_keyboard,omitempty"`
+	InputFieldPlaceholder string             `json:"input_field_placeholder,omitempty"`
+	Selective             bool               `json:"selective,omitempty"` // Show only to mentioned users and the sender of the replied message
+}
+
+// NewReplyKeyboard creates a resized reply keyboard with one row per call to Row
+func NewReplyKeyboard() *ReplyKeyboardMarkup {
+	return &ReplyKeyboardMarkup{Keyboard: [][]KeyboardButton{}, ResizeKeyboard: true}
+}
+
+// Row appends a row of buttons with the given texts
+func (k *ReplyKeyboardMarkup) Row(texts ...string) *ReplyKeyboardMarkup {
+	row := make([]KeyboardButton, 0, len(texts))
+	for _, text := range texts {
+		row = append(row, KeyboardButton{Text: text})
+	}
+	k.Keyboard = append(k.Keyboard, row)
+	return k
+}
+
+// Buttons appends a row of buttons, e.g. to request a contact or location
+func (k *ReplyKeyboardMarkup) Buttons(buttons ...KeyboardButton) *ReplyKeyboardMarkup {
+	k.Keyboard = append(k.Keyboard, buttons)
+	return k
+}
+
+// OneTime hides the keyboard once a button has been pressed
+func (k *ReplyKeyboardMarkup) OneTime() *ReplyKeyboardMarkup {
+	k.OneTimeKeyboard = true
+	return k
+}
+
+// Placeholder sets the placeholder of the input field while the keyboard is shown
+func (k *ReplyKeyboardMarkup) Placeholder(text string) *ReplyKeyboardMarkup {
+	k.InputFieldPlaceholder = text
+	return k
+}
+
+// ReplyKeyboardRemove removes a reply keyboard. Set it as Message.Data["reply_markup"].
+type ReplyKeyboardRemove struct {
+	RemoveKeyboard bool `json:"remove_keyboard"` // Always true
+	Selective      bool `json:"selective,omitempty"`
+}
+
+// RemoveKeyboard creates a ReplyKeyboardRemove
+func RemoveKeyboard() *ReplyKeyboardRemove {
+	return &ReplyKeyboardRemove{RemoveKeyboard: true}
+}
@@ -0,0 +1,401 @@
+package telegram
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+// Parse modes MessageTypeMarkdown content can be converted to
+const (
+	ParseModeMarkdownV2 = "MarkdownV2"
+	ParseModeHTML       = "HTML"
+)
+
+// Block-level markdown syntax
+var (
+	headingPattern     = regexp.MustCompile(`^#{1,6}\s+(.*?)\s*#*\s*$`)
+	rulePattern        = regexp.MustCompile(`^\s*([-*_])(\s*([-*_]))(\s*([-*_]))+\s*$`)
+	quotePattern       = regexp.MustCompile(`^\s*>\s?(.*)$`)
+	bulletPattern      = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	orderedPattern     = regexp.MustCompile(`^(\s*)(\d+)[.)]\s+(.*)$`)
+	fencePattern       = regexp.MustCompile("^\\s*(```+|~~~+)\\s*([\\w+#.-]*)\\s*$")
+	markdownV2Reserved = strings.NewReplacer(
+		`\`, `\\`, `_`, `\_`, `*`, `\*`, `[`, `\[`, `]`, `\]`, `(`, `\(`, `)`, `\)`, `~`, `\~`, "`", "\\`",
+		`>`, `\>`, `#`, `\#`, `+`, `\+`, `-`, `\-`, `=`, `\=`, `|`, `\|`, `{`, `\{`, `}`, `\}`, `.`, `\.`, `!`, `\!`,
+	)
+	markdownV2Code = strings.NewReplacer(`\`, `\\`, "`", "\\`")
+	markdownV2URL  = strings.NewReplacer(`\`, `\\`, `)`, `\)`)
+	htmlEscaper    = strings.NewReplacer(`&`, `&amp;`, `<`, `&lt;`, `>`, `&gt;`, `"`, `&quot;`)
+)
+
+// EscapeMarkdownV2 escapes every character reserved by the MarkdownV2 parse mode
+func EscapeMarkdownV2(text string) string {
+	return markdownV2Reserved.Replace(text)
+}
+
+// ToMarkdownV2 converts common markdown (the dialect accepted by DingTalk, WeChat
+// Work and Lark) to Telegram's MarkdownV2: bold, italic, strikethrough, inline code,
+// code blocks, links and quotes are kept, headings become bold lines, list items
+// get bullets, and every other reserved character is escaped.
+// 参考: https://core.telegram.org/bots/api#markdownv2-style
+func ToMarkdownV2(markdown string) string {
+	return convertMarkdown(markdown, markdownV2Renderer{})
+}
+
+// ToHTML converts common markdown to Telegram's HTML parse mode, see ToMarkdownV2.
+// 参考: https://core.telegram.org/bots/api#html-style
+func ToHTML(markdown string) string {
+	return convertMarkdown(markdown, htmlRenderer{})
+}
+
+// markdownText returns the text and parse mode of a markdown message. Content is
+// converted for parseMode, unless the caller chose a parse mode in msg.Data and
+// formatted the content for it.
+func markdownText(msg *types.Message, parseMode string) (string, interface{}) {
+	if mode, ok := msg.Data["parse_mode"]; ok {
+		return msg.Content, mode
+	}
+	if parseMode == ParseModeHTML {
+		return ToHTML(msg.Content), ParseModeHTML
+	}
+	return ToMarkdownV2(msg.Content), ParseModeMarkdownV2
+}
+
+// renderer emits the entities of a parse mode
+type renderer interface {
+	text(s string) string
+	bold(inner string) string
+	italic(inner string) string
+	strike(inner string) string
+	code(s string) string
+	pre(lang, s string) string
+	link(inner, url string) string
+	quote(lines []string) string
+}
+
+// markdownV2Renderer renders MarkdownV2 entities
+type markdownV2Renderer struct{}
+
+func (markdownV2Renderer) text(s string) string {
+	return EscapeMarkdownV2(s)
+}
+
+func (markdownV2Renderer) bold(inner string) string {
+	return "*" + inner + "*"
+}
+
+func (markdownV2Renderer) italic(inner string) string {
+	return "_" + inner + "_"
+}
+
+func (markdownV2Renderer) strike(inner string) string {
+	return "~" + inner + "~"
+}
+
+func (markdownV2Renderer) code(s string) string {
+	return "`" + markdownV2Code.Replace(s) + "`"
+}
+
+func (markdownV2Renderer) link(inner, url string) string {
+	return "[" + inner + "](" + markdownV2URL.Replace(url) + ")"
+}
+
+func (markdownV2Renderer) pre(lang, s string) string {
+	return "```" + lang + "\n" + markdownV2Code.Replace(s) + "\n```"
+}
+
+func (markdownV2Renderer) quote(lines []string) string {
+	return ">" + strings.Join(lines, "\n>")
+}
+
+// htmlRenderer renders HTML entities
+type htmlRenderer struct{}
+
+func (htmlRenderer) text(s string) string {
+	return htmlEscaper.Replace(s)
+}
+
+func (htmlRenderer) bold(inner string) string {
+	return "<b>" + inner + "</b>"
+}
+
+func (htmlRenderer) italic(inner string) string {
+	return "<i>" + inner + "</i>"
+}
+
+func (htmlRenderer) strike(inner string) string {
+	return "<s>" + inner + "</s>"
+}
+
+func (htmlRenderer) code(s string) string {
+	return "<code>" + htmlEscaper.Replace(s) + "</code>"
+}
+
+func (htmlRenderer) link(inner, url string) string {
+	return `<a href="` + htmlEscaper.Replace(url) + `">` + inner + "</a>"
+}
+
+func (htmlRenderer) pre(lang, s string) string {
+	if lang == "" {
+		return "<pre>" + htmlEscaper.Replace(s) + "</pre>"
+	}
+	return `<pre><code class="language-` + htmlEscaper.Replace(lang) + `">` + htmlEscaper.Replace(s) + "</code></pre>"
+}
+
+func (htmlRenderer) quote(lines []string) string {
+	return "<blockquote>" + strings.Join(lines, "\n") + "</blockquote>"
+}
+
+// headingRenderer renders the content of a heading, which is bolded as a whole:
+// "# **Title**" would otherwise nest bold entities, which MarkdownV2 reads as
+// empty bold spans around plain text
+type headingRenderer struct {
+	renderer
+}
+
+func (headingRenderer) bold(inner string) string {
+	return inner
+}
+
+// convertMarkdown converts markdown line by line, handling code fences, headings,
+// rules, quotes and lists, and passes the text of each line to convertInline
+func convertMarkdown(markdown string, r renderer) string {
+	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+	out := make([]string, 0, len(lines))
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if m := fencePattern.FindStringSubmatch(line); m != nil {
+			// Code block, up to the closing fence or the end of the text
+			fence := m[1]
+			var code []string
+			for i++; i < len(lines); i++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), fence[:3]) && strings.Trim(strings.TrimSpace(lines[i]), fence[:1]) == "" {
+					break
+				}
+				code = append(code, lines[i])
+			}
+			out = append(out, r.pre(m[2], strings.Join(code, "\n")))
+			continue
+		}
+
+		if m := quotePattern.FindStringSubmatch(line); m != nil {
+			quoted := []string{convertInline(m[1], r)}
+			for i+1 < len(lines) {
+				next := quotePattern.FindStringSubmatch(lines[i+1])
+				if next == nil {
+					break
+				}
+				quoted = append(quoted, convertInline(next[1], r))
+				i++
+			}
+			out = append(out, r.quote(quoted))
+			continue
+		}
+
+		switch {
+		case headingPattern.MatchString(line):
+			// The whole line is bold, so bold spans in it only keep their text
+			out = append(out, r.bold(convertInline(headingPattern.FindStringSubmatch(line)[1], headingRenderer{r})))
+		case rulePattern.MatchString(line):
+			out = append(out, r.text("——————"))
+		case bulletPattern.MatchString(line):
+			m := bulletPattern.FindStringSubmatch(line)
+			out = append(out, m[1]+r.text("• ")+convertInline(m[2], r))
+		case orderedPattern.MatchString(line):
+			m := orderedPattern.FindStringSubmatch(line)
+			out = append(out, m[1]+r.text(m[2]+". ")+convertInline(m[3], r))
+		default:
+			out = append(out, convertInline(line, r))
+		}
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// convertInline converts the inline syntax of a line: **bold**, __bold__, *italic*,
+// _italic_, ~~strikethrough~~, `code`, [links](url) and ![images](url) (as links).
+// Backslash escapes and unmatched delimiters are kept as literal text.
+func convertInline(s string, r renderer) string {
+	var out, text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			out.WriteString(r.text(text.String()))
+			text.Reset()
+		}
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		switch {
+		case c == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]):
+			text.WriteByte(s[i+1])
+			i += 2
+			continue
+
+		case c == '`':
+			run := delimiterRun(s, i, '`')
+			if end := strings.Index(s[i+run:], s[i:i+run]); end >= 0 {
+				flush()
+				out.WriteString(r.code(strings.TrimSpace(s[i+run : i+run+end])))
+				i += run + end + run
+				continue
+			}
+			text.WriteString(s[i : i+run])
+			i += run
+			continue
+
+		case c == '[' || (c == '!' && i+1 < len(s) && s[i+1] == '['):
+			start := i
+			if c == '!' {
+				start++
+			}
+			if label, url, n := parseLink(s[start:]); n > 0 {
+				flush()
+				out.WriteString(r.link(convertInline(label, r), url))
+				i = start + n
+				continue
+			}
+
+		case (c == '*' || c == '_' || c == '~') && i+1 < len(s) && s[i+1] == c:
+			if inner, n := parseEmphasis(s, i, s[i:i+2]); n > 0 {
+				flush()
+				converted := convertInline(inner, r)
+				if c == '~' {
+					out.WriteString(r.strike(converted))
+				} else {
+					out.WriteString(r.bold(converted))
+				}
+				i += n
+				continue
+			}
+			if c == '~' {
+				break
+			}
+			text.WriteString(s[i : i+2])
+			i += 2
+			continue
+
+		case c == '*' || c == '_':
+			if inner, n := parseEmphasis(s, i, s[i:i+1]); n > 0 {
+				flush()
+				out.WriteString(r.italic(convertInline(inner, r)))
+				i += n
+				continue
+			}
+		}
+
+		text.WriteByte(c)
+		i++
+	}
+
+	flush()
+	return out.String()
+}
+
+// parseEmphasis matches an emphasis opened by delim at s[i]. The delimiters must
+// hug the text, and underscores must not be inside a word (snake_case stays as is).
+// It returns the enclosed text and the length of the whole span, or 0.
+func parseEmphasis(s string, i int, delim string) (string, int) {
+	open := i + len(delim)
+	if open >= len(s) || s[open] == ' ' {
+		return "", 0
+	}
+	if delim[0] == '_' && i > 0 && isWordByte(s[i-1]) {
+		return "", 0
+	}
+
+	for j := open + 1; j+len(delim) <= len(s); j++ {
+		if s[j:j+len(delim)] != delim || s[j-1] == ' ' || s[j-1] == '\\' {
+			continue
+		}
+		// A single delimiter must not be part of a double one, e.g. the ** of a bold span
+		if len(delim) == 1 && j+1 < len(s) && s[j+1] == delim[0] {
+			j++
+			continue
+		}
+		// A double delimiter closes at the end of a longer run: **bold *italic***
+		if len(delim) == 2 && j+2 < len(s) && s[j+2] == delim[0] {
+			continue
+		}
+		if delim[0] == '_' && j+len(delim) < len(s) && isWordByte(s[j+len(delim)]) {
+			continue
+		}
+		return s[open:j], j + len(delim) - i
+	}
+	return "", 0
+}
+
+// parseLink matches [label](url) at the start of s and returns its parts and length, or 0
+func parseLink(s string) (label, url string, n int) {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth > 0 {
+				continue
+			}
+			if i+1 >= len(s) || s[i+1] != '(' {
+				return "", "", 0
+			}
+			end := closingParen(s[i+2:])
+			if end < 0 {
+				return "", "", 0
+			}
+			url = strings.TrimSpace(s[i+2 : i+2+end])
+			// Drop an optional title: [label](url "title")
+			if space := strings.IndexByte(url, ' '); space >= 0 {
+				url = url[:space]
+			}
+			if url == "" {
+				return "", "", 0
+			}
+			return s[1:i], url, i + 2 + end + 1
+		}
+	}
+	return "", "", 0
+}
+
+// closingParen returns the index of the ')' closing a link destination, allowing
+// balanced parentheses inside it, or -1
+func closingParen(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// delimiterRun returns the number of consecutive c at s[i:]
+func delimiterRun(s string, i int, c byte) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == c {
+		n++
+	}
+	return n
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func isASCIIPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
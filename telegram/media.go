@@ -0,0 +1,244 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path/filepath"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+// UploadKey is the Message.Data key of a file to upload for media messages.
+// The value is an *InputFile or any io.Reader (e.g. an *os.File); when it is
+// set, Content may be left empty instead of holding a file_id or URL.
+const UploadKey = "file"
+
+// maxUploadSize is the Bot API limit for files uploaded with multipart/form-data
+const maxUploadSize = 50 << 20
+
+// mediaMethod is the Bot API method sending a media message and its file parameter
+type mediaMethod struct {
+	method string
+	field  string
+}
+
+// mediaMethods maps media message types to their Bot API methods.
+// Captions and parse modes are passed in Message.Data["caption"] and Data["parse_mode"].
+// 参考: https://core.telegram.org/bots/api#sendphoto
+var mediaMethods = map[types.MessageType]mediaMethod{
+	types.MessageTypeImage:   {"sendPhoto", "photo"},
+	types.MessageTypeFile:    {"sendDocument", "document"},
+	types.MessageTypeAudio:   {"sendAudio", "audio"},
+	types.MessageTypeMedia:   {"sendVideo", "video"},
+	types.MessageTypeSticker: {"sendSticker", "sticker"},
+}
+
+// InputFile is a file uploaded with multipart/form-data. Its content is held in
+// memory so the same upload can go to several targets and be retried.
+type InputFile struct {
+	Name string // File name shown to users
+	Data []byte
+}
+
+// NewInputFile reads r into an InputFile (up to 50 MB)
+func NewInputFile(name string, r io.Reader) (*InputFile, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxUploadSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if len(data) > maxUploadSize {
+		return nil, fmt.Errorf("%s exceeds the 50 MB upload limit", name)
+	}
+	return &InputFile{Name: name, Data: data}, nil
+}
+
+// bufferUploads returns msg with every io.Reader in Data read into an InputFile
+func bufferUploads(msg *types.Message) (*types.Message, error) {
+	var data map[string]interface{}
+	for k, v := range msg.Data {
+		r, ok := v.(io.Reader)
+		if !ok {
+			continue
+		}
+		if data == nil {
+			data = make(map[string]interface{}, len(msg.Data))
+			for k, v := range msg.Data {
+				data[k] = v
+			}
+		}
+
+		// Files opened with os.Open keep their name
+		name := k
+		if named, ok := r.(interface{ Name() string }); ok {
+			name = filepath.Base(named.Name())
+		}
+
+		file, err := NewInputFile(name, r)
+		if err != nil {
+			return nil, err
+		}
+		data[k] = file
+	}
+
+	if data == nil {
+		return msg, nil
+	}
+	buffered := *msg
+	buffered.Data = data
+	return &buffered, nil
+}
+
+// encodeParams encodes method parameters as JSON, or as multipart/form-data when
+// one of them is an *InputFile, and returns the body with its content type
+func encodeParams(params map[string]interface{}) (io.Reader, string, error) {
+	upload := false
+	for _, v := range params {
+		if _, ok := v.(*InputFile); ok {
+			upload = true
+			break
+		}
+	}
+
+	if !upload {
+		body, err := json.Marshal(params)
+		if err != nil {
+			return nil, "", err
+		}
+		return bytes.NewReader(body), "application/json", nil
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for k, v := range params {
+		switch v := v.(type) {
+		case *InputFile:
+			part, err := writer.CreateFormFile(k, v.Name)
+			if err != nil {
+				return nil, "", err
+			}
+			if _, err := part.Write(v.Data); err != nil {
+				return nil, "", err
+			}
+		case string:
+			if err := writer.WriteField(k, v); err != nil {
+				return nil, "", err
+			}
+		default:
+			// Numbers, booleans and objects such as reply_markup are JSON-serialized
+			value, err := json.Marshal(v)
+			if err != nil {
+				return nil, "", err
+			}
+			if err := writer.WriteField(k, string(value)); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return &body, writer.FormDataContentType(), nil
+}
+
+// Input media types of SendMediaGroup
+const (
+	InputMediaPhoto    = "photo"
+	InputMediaVideo    = "video"
+	InputMediaAudio    = "audio"
+	InputMediaDocument = "document"
+)
+
+// InputMedia is an item of an album sent with SendMediaGroup
+type InputMedia struct {
+	Type      string     // InputMediaPhoto, InputMediaVideo, InputMediaAudio or InputMediaDocument
+	Media     string     // file_id or HTTP URL, ignored when File is set
+	File      *InputFile // Optional: file to upload
+	Caption   string     // Optional: caption, 0-1024 characters
+	ParseMode string     // Optional: parse mode of the caption, e.g. "MarkdownV2" or "HTML"
+}
+
+// SendMediaGroup sends 2-10 photos and videos, or documents, or audios as an album
+// (sendMediaGroup) to every target. The receipt of each target carries the ID of
+// the first message of the album; Raw holds all of them.
+// 参考: https://core.telegram.org/bots/api#sendmediagroup
+func (c *Client) SendMediaGroup(ctx context.Context, media []InputMedia, opts *types.SendOptions) ([]types.SendResult, error) {
+	if opts == nil || len(opts.Targets) == 0 {
+		return nil, fmt.Errorf("at least one target is required")
+	}
+	if len(media) < 2 || len(media) > 10 {
+		return nil, fmt.Errorf("a media group must contain 2-10 items, got %d", len(media))
+	}
+
+	// Uploads are attached as separate parts and referenced with attach://<name>
+	items := make([]map[string]interface{}, 0, len(media))
+	attachments := make(map[string]*InputFile)
+	for i, m := range media {
+		item := map[string]interface{}{
+			"type":  m.Type,
+			"media": m.Media,
+		}
+		if m.File != nil {
+			name := fmt.Sprintf("file%d", i)
+			attachments[name] = m.File
+			item["media"] = "attach://" + name
+		} else if m.Media == "" {
+			return nil, fmt.Errorf("media group item %d has neither Media nor File", i)
+		}
+		if m.Caption != "" {
+			item["caption"] = m.Caption
+		}
+		if m.ParseMode != "" {
+			item["parse_mode"] = m.ParseMode
+		}
+		items = append(items, item)
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = c.config.Concurrency
+	}
+
+	return types.FanOut(ctx, opts.Targets, concurrency, func(ctx context.Context, target types.Target) (*types.SendResult, error) {
+		var result *types.SendResult
+		err := c.retry.Do(ctx, func(ctx context.Context) error {
+			if err := c.limiter.Wait(ctx, target.Key()); err != nil {
+				return err
+			}
+
+			reqBody := map[string]interface{}{
+				"chat_id": target.ID,
+				"media":   items,
+			}
+			for name, file := range attachments {
+				reqBody[name] = file
+			}
+
+			respBody, err := c.callMethod(ctx, "sendMediaGroup", reqBody)
+			if err != nil {
+				if apiErr, ok := types.AsAPIError(err); ok {
+					c.limiter.Pause(target.Key(), apiErr.RetryAfter)
+				}
+				return err
+			}
+
+			var apiResp struct {
+				Result []sentMessage `json:"result"`
+			}
+			if err := json.Unmarshal(respBody, &apiResp); err != nil {
+				return err
+			}
+			if len(apiResp.Result) == 0 {
+				return fmt.Errorf("sendMediaGroup returned no messages")
+			}
+
+			result = apiResp.Result[0].toResult(target, respBody)
+			return nil
+		})
+		return result, err
+	})
+}
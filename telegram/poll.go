@@ -0,0 +1,168 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+// defaultPollTimeout is the long polling timeout used when Config.PollTimeout is
+// not set. It stays below the default 30s http.Client timeout.
+const defaultPollTimeout = 25 * time.Second
+
+// pollBackoff spaces out getUpdates calls after transient failures
+var pollBackoff = &types.RetryPolicy{
+	InitialBackoff: time.Second,
+	MaxBackoff:     30 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// ErrPolling is returned by Poll when another Poll is already running on the client
+var ErrPolling = errors.New("telegram: Poll is already running")
+
+// Poll receives updates with getUpdates long polling and passes them to handler
+// in order, for deployments that can't expose a webhook URL. Telegram refuses
+// getUpdates while a webhook is set, call deleteWebhook first.
+//
+// Each update is confirmed (the offset moves past it) once handler returns nil.
+// Updates that can't be decoded are skipped and confirmed without calling
+// handler, so a single malformed update doesn't block the ones after it.
+// Transient getUpdates failures are retried with exponential backoff; Poll
+// returns when ctx is done, the client is closed (nil in both cases), on a
+// permanent error such as an invalid token, or when handler fails. Updates that
+// weren't confirmed are delivered again by the next Poll.
+func (c *Client) Poll(ctx context.Context, handler UpdateHandler) error {
+	if handler == nil {
+		return fmt.Errorf("handler cannot be nil")
+	}
+
+	c.closedMu.Lock()
+	switch {
+	case c.closed:
+		c.closedMu.Unlock()
+		return fmt.Errorf("client is closed")
+	case c.polling:
+		c.closedMu.Unlock()
+		return ErrPolling
+	}
+	c.polling = true
+	c.closedMu.Unlock()
+
+	defer func() {
+		c.closedMu.Lock()
+		c.polling = false
+		c.closedMu.Unlock()
+	}()
+
+	// Stop polling when the client is closed
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-c.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	timeout := c.config.PollTimeout
+	if timeout <= 0 {
+		timeout = defaultPollTimeout
+	}
+
+	var offset int64
+	failures := 0
+	for {
+		updates, next, err := c.getUpdates(ctx, offset, timeout)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			if !types.IsRetryable(err) {
+				return err
+			}
+
+			wait := pollBackoff.Backoff(failures)
+			if apiErr, ok := types.AsAPIError(err); ok && apiErr.RetryAfter > wait {
+				wait = apiErr.RetryAfter
+			}
+			failures++
+
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil
+			}
+			continue
+		}
+		failures = 0
+
+		for _, update := range updates {
+			if err := handler(ctx, update); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("handler failed for update %d: %w", update.UpdateID, err)
+			}
+			offset = update.UpdateID + 1
+		}
+		// Confirm the skipped updates too
+		if next > offset {
+			offset = next
+		}
+	}
+}
+
+// getUpdates fetches the updates after offset, waiting up to timeout for new ones.
+// Updates that can't be decoded are left out; next is the offset confirming all
+// the returned updates, including those.
+func (c *Client) getUpdates(ctx context.Context, offset int64, timeout time.Duration) (updates []*Update, next int64, err error) {
+	reqBody := map[string]interface{}{
+		"timeout": int(timeout / time.Second),
+	}
+	if offset > 0 {
+		reqBody["offset"] = offset
+	}
+	if c.config.AllowedUpdates != nil {
+		reqBody["allowed_updates"] = c.config.AllowedUpdates
+	}
+
+	respBody, err := c.callMethod(ctx, "getUpdates", reqBody)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var apiResp struct {
+		Result []json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return nil, 0, err
+	}
+
+	updates = make([]*Update, 0, len(apiResp.Result))
+	for _, raw := range apiResp.Result {
+		// Only update_id is needed to skip an update the full decode rejects
+		var id struct {
+			UpdateID int64 `json:"update_id"`
+		}
+		if err := json.Unmarshal(raw, &id); err == nil && id.UpdateID >= next {
+			next = id.UpdateID + 1
+		}
+
+		var update Update
+		if err := json.Unmarshal(raw, &update); err != nil {
+			continue
+		}
+		update.raw = raw
+		updates = append(updates, &update)
+	}
+
+	return updates, next, nil
+}
@@ -1,12 +1,12 @@
 package telegram
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
@@ -20,8 +20,15 @@ const (
 
 // Config represents Telegram bot configuration
 type Config struct {
-	BotToken string
-	BaseURL  string // Optional: custom base URL (for proxy or test)
+	BotToken    string
+	BaseURL     string             // Optional: custom base URL (for proxy or test)
+	RetryPolicy *types.RetryPolicy // Optional: retry policy for failed sends (default: types.DefaultRetryPolicy())
+	RateLimit   *types.RateLimit   // Optional: client-side rate limit (default: DefaultRateLimit(), &types.RateLimit{} disables it)
+	Concurrency int                // Optional: targets sent to in parallel by SendMessage (default: 1)
+	ParseMode   string             // Optional: parse mode markdown messages are converted to, ParseModeMarkdownV2 or ParseModeHTML (default: ParseModeMarkdownV2)
+
+	PollTimeout    time.Duration // Optional: getUpdates long polling timeout used by Poll (default: 25s, keep it below the http.Client timeout)
+	AllowedUpdates []string      // Optional: update types Poll asks for, e.g. []string{"message", "callback_query"} (default: Telegram's default set)
 }
 
 // Validate validates the config
@@ -29,6 +36,9 @@ func (c *Config) Validate() error {
 	if c.BotToken == "" {
 		return fmt.Errorf("BotToken is required")
 	}
+	if c.ParseMode != "" && c.ParseMode != ParseModeMarkdownV2 && c.ParseMode != ParseModeHTML {
+		return fmt.Errorf("ParseMode must be %s or %s", ParseModeMarkdownV2, ParseModeHTML)
+	}
 	return nil
 }
 
@@ -37,12 +47,28 @@ func (c *Config) GetPlatform() string {
 	return "telegram"
 }
 
+// DefaultRateLimit returns the quotas applied when Config.RateLimit is nil:
+// about 30 messages per second overall and 1 per second to the same chat
+func DefaultRateLimit() *types.RateLimit {
+	return &types.RateLimit{Rate: 30, Burst: 30, PerTargetRate: 1, PerTargetBurst: 1}
+}
+
+// MessageLimit returns the content length limit of a message type, used to split
+// messages when SendOptions.Split is set: 4096 characters after entity parsing
+func MessageLimit(msgType types.MessageType) types.Limit {
+	return types.Limit{Max: 4096, Unit: types.LengthUTF16}
+}
+
 // Client implements IMParrot interface for Telegram
 type Client struct {
 	config     *Config
 	httpClient *http.Client
 	ownsHTTP   bool // Whether the client owns the http.Client and should close it
+	retry      *types.RetryPolicy
+	limiter    *types.RateLimiter
 	apiURL     string
+	polling    bool          // Whether Poll is running
+	done       chan struct{} // Closed by Close to stop Poll
 	closed     bool
 	closedMu   sync.RWMutex
 }
@@ -55,6 +81,16 @@ func NewClient(config *Config, httpClient *http.Client) (*Client, error) {
 		ownsHTTP = true
 	}
 
+	retry := config.RetryPolicy
+	if retry == nil {
+		retry = types.DefaultRetryPolicy()
+	}
+
+	rateLimit := config.RateLimit
+	if rateLimit == nil {
+		rateLimit = DefaultRateLimit()
+	}
+
 	baseURL := config.BaseURL
 	if baseURL == "" {
 		baseURL = telegramAPIBase
@@ -64,7 +100,10 @@ func NewClient(config *Config, httpClient *http.Client) (*Client, error) {
 		config:     config,
 		httpClient: httpClient,
 		ownsHTTP:   ownsHTTP,
+		retry:      retry,
+		limiter:    types.NewRateLimiter("telegram", rateLimit),
 		apiURL:     baseURL + config.BotToken,
+		done:       make(chan struct{}),
 	}, nil
 }
 
@@ -75,135 +114,250 @@ func (c *Client) GetPlatformName() string {
 
 // SendMessage sends a message with options (Strategy pattern implementation)
 func (c *Client) SendMessage(ctx context.Context, msg *types.Message, opts *types.SendOptions) error {
+	_, err := c.SendMessageWithResult(ctx, msg, opts)
+	return err
+}
+
+// SendMessageWithResult sends a message and returns a receipt for every delivered target
+func (c *Client) SendMessageWithResult(ctx context.Context, msg *types.Message, opts *types.SendOptions) ([]types.SendResult, error) {
 	if msg == nil || opts == nil {
-		return fmt.Errorf("message and options cannot be nil")
+		return nil, fmt.Errorf("message and options cannot be nil")
 	}
 
 	if len(opts.Targets) == 0 {
-		return fmt.Errorf("at least one target is required")
-	}
-
-	// Send to multiple targets with retry
-	const maxRetries = 3
-	failedTargets := make([]types.FailedTarget, 0)
-	successCount := 0
-
-	for _, target := range opts.Targets {
-		var lastErr error
-		sent := false
-
-		// Retry up to maxRetries times for each target
-		for retry := 0; retry < maxRetries; retry++ {
-			if err := c.sendToSingleTarget(ctx, msg, target); err != nil {
-				lastErr = err
-				// Wait a bit before retrying (exponential backoff)
-				if retry < maxRetries-1 {
-					time.Sleep(time.Duration(100*(retry+1)) * time.Millisecond)
-				}
-			} else {
-				sent = true
-				successCount++
-				break
-			}
-		}
+		return nil, fmt.Errorf("at least one target is required")
+	}
+
+	// Read uploads once so they can be sent to every target and retried
+	msg, err := bufferUploads(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = c.config.Concurrency
+	}
 
-		// Record failed target after all retries exhausted
-		if !sent {
-			failedTargets = append(failedTargets, types.FailedTarget{
-				Target: target,
-				Error:  lastErr,
+	// Fan out to the targets, retrying each (part) according to the retry policy
+	return types.FanOut(ctx, opts.Targets, concurrency, func(ctx context.Context, target types.Target) (*types.SendResult, error) {
+		return types.SendSplit(ctx, msg, opts, MessageLimit(msg.Type), func(ctx context.Context, msg *types.Message) (*types.SendResult, error) {
+			var result *types.SendResult
+			err := c.retry.Do(ctx, func(ctx context.Context) error {
+				var err error
+				result, err = c.sendToSingleTarget(ctx, msg, target)
+				return err
 			})
-		}
+			return result, err
+		})
+	})
+}
+
+// sendToSingleTarget sends a message to a single target
+func (c *Client) sendToSingleTarget(ctx context.Context, msg *types.Message, target types.Target) (*types.SendResult, error) {
+	// Wait for the client-wide and per-target quotas before every attempt
+	if err := c.limiter.Wait(ctx, target.Key()); err != nil {
+		return nil, err
 	}
 
-	// Return error with failed targets information
-	if len(failedTargets) > 0 {
-		return &types.SendError{
-			FailedTargets: failedTargets,
-			SuccessCount:  successCount,
-			TotalCount:    len(opts.Targets),
+	method, reqBody := buildRequest(msg, target.ID, c.config.ParseMode)
+
+	respBody, err := c.callMethod(ctx, method, reqBody)
+	if err != nil {
+		// Telegram tells us how long the chat is throttled, hold further sends until then
+		if apiErr, ok := types.AsAPIError(err); ok {
+			c.limiter.Pause(target.Key(), apiErr.RetryAfter)
 		}
+		return nil, err
 	}
 
-	return nil
+	var apiResp struct {
+		Result sentMessage `json:"result"`
+	}
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return nil, err
+	}
+
+	return apiResp.Result.toResult(target, respBody), nil
 }
 
-// sendToSingleTarget sends a message to a single target
-func (c *Client) sendToSingleTarget(ctx context.Context, msg *types.Message, target types.Target) error {
-	// Build request body
-	reqBody := map[string]interface{}{
-		"chat_id": target.ID,
+// buildRequest picks the Bot API method for a message and builds its parameters
+func buildRequest(msg *types.Message, chatID, parseMode string) (method string, reqBody map[string]interface{}) {
+	reqBody = map[string]interface{}{
+		"chat_id": chatID,
 	}
 
+	// Media messages reference a file_id or URL in Content, or upload Data[UploadKey]
+	media, isMedia := mediaMethods[msg.Type]
+
 	// Set message content based on type
-	switch msg.Type {
-	case types.MessageTypeText:
-		reqBody["text"] = msg.Content
-	case types.MessageTypeMarkdown:
+	switch {
+	case isMedia:
+		method = media.method
+		if msg.Content != "" {
+			reqBody[media.field] = msg.Content
+		}
+	case msg.Type == types.MessageTypeMarkdown:
+		method = "sendMessage"
+		reqBody["text"], reqBody["parse_mode"] = markdownText(msg, parseMode)
+	default:
+		method = "sendMessage"
 		reqBody["text"] = msg.Content
-		reqBody["parse_mode"] = "MarkdownV2"
+	}
+
+	// Add extra options from msg.Data (e.g. caption, parse_mode, reply_markup)
+	for k, v := range msg.Data {
+		if isMedia && k == UploadKey {
+			reqBody[media.field] = v
+			continue
+		}
+		reqBody[k] = v
+	}
+
+	return method, reqBody
+}
+
+// sentMessage is the Message object returned by the send methods
+type sentMessage struct {
+	MessageID int64 `json:"message_id"`
+	Date      int64 `json:"date"`
+	Chat      struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+}
+
+// toResult converts the sent message into a send receipt
+func (m sentMessage) toResult(target types.Target, raw []byte) *types.SendResult {
+	timestamp := time.Now()
+	if m.Date > 0 {
+		timestamp = time.Unix(m.Date, 0)
+	}
+
+	return &types.SendResult{
+		Target:    target,
+		MessageID: strconv.FormatInt(m.MessageID, 10),
+		ChatID:    strconv.FormatInt(m.Chat.ID, 10),
+		Timestamp: timestamp,
+		Raw:       raw,
+	}
+}
+
+// SendPrivateMessage sends a private message to a user
+func (c *Client) SendPrivateMessage(ctx context.Context, userID string, msg *types.Message) error {
+	return c.SendMessage(ctx, msg, &types.SendOptions{
+		Targets: []types.Target{{ID: userID, ChatType: types.ChatTypePrivate}},
+	})
+}
+
+// SendGroupMessage sends a message to a group
+func (c *Client) SendGroupMessage(ctx context.Context, groupID string, msg *types.Message) error {
+	return c.SendMessage(ctx, msg, &types.SendOptions{
+		Targets: []types.Target{{ID: groupID, ChatType: types.ChatTypeGroup}},
+	})
+}
+
+// UpdateMessage edits the text of a sent message (editMessageText). For photo,
+// document, audio and video messages, whose msg.Type is the media type, the
+// caption in msg.Data["caption"] is edited instead (editMessageCaption); the
+// media itself and stickers can't be changed.
+func (c *Client) UpdateMessage(ctx context.Context, receipt *types.SendResult, msg *types.Message) error {
+	if receipt == nil || receipt.MessageID == "" || msg == nil {
+		return fmt.Errorf("receipt with message ID and message cannot be nil")
+	}
+
+	reqBody := map[string]interface{}{
+		"chat_id":    receiptChatID(receipt),
+		"message_id": receipt.MessageID,
+	}
+
+	method := "editMessageText"
+	switch {
+	case msg.Type == types.MessageTypeSticker:
+		return &types.UnsupportedError{Platform: "telegram", Operation: "editing stickers"}
+	case mediaMethods[msg.Type].method != "":
+		method = "editMessageCaption"
+	case msg.Type == types.MessageTypeMarkdown:
+		reqBody["text"], reqBody["parse_mode"] = markdownText(msg, c.config.ParseMode)
 	default:
 		reqBody["text"] = msg.Content
 	}
 
-	// Add extra options from msg.Data
-	if msg.Data != nil {
-		for k, v := range msg.Data {
-			reqBody[k] = v
+	// Add extra options from msg.Data (e.g. caption, reply_markup)
+	for k, v := range msg.Data {
+		if k == UploadKey {
+			continue
 		}
+		reqBody[k] = v
 	}
 
-	body, err := json.Marshal(reqBody)
+	_, err := c.callMethod(ctx, method, reqBody)
+	return err
+}
+
+// RecallMessage deletes a sent message (deleteMessage)
+func (c *Client) RecallMessage(ctx context.Context, receipt *types.SendResult) error {
+	if receipt == nil || receipt.MessageID == "" {
+		return fmt.Errorf("receipt with message ID cannot be nil")
+	}
+
+	_, err := c.callMethod(ctx, "deleteMessage", map[string]interface{}{
+		"chat_id":    receiptChatID(receipt),
+		"message_id": receipt.MessageID,
+	})
+	return err
+}
+
+// receiptChatID returns the chat a receipt refers to, falling back to its target
+func receiptChatID(receipt *types.SendResult) string {
+	if receipt.ChatID != "" {
+		return receipt.ChatID
+	}
+	return receipt.Target.ID
+}
+
+// callMethod invokes a Bot API method and returns the raw response once the API
+// has reported success. The body is JSON, or multipart/form-data when a parameter
+// is an *InputFile to upload.
+func (c *Client) callMethod(ctx context.Context, method string, reqBody map[string]interface{}) ([]byte, error) {
+	body, contentType, err := encodeParams(reqBody)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	url := fmt.Sprintf("%s/sendMessage", c.apiURL)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	url := fmt.Sprintf("%s/%s", c.apiURL, method)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", contentType)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var apiResp struct {
-		OK          bool   `json:"ok"`
-		Description string `json:"description"`
+		OK          bool           `json:"ok"`
+		ErrorCode   int            `json:"error_code"`
+		Description string         `json:"description"`
+		Parameters  responseParams `json:"parameters"`
 	}
 
 	if err := json.Unmarshal(respBody, &apiResp); err != nil {
-		return err
+		return nil, err
 	}
 
 	if !apiResp.OK {
-		return fmt.Errorf("telegram API error: %s", apiResp.Description)
+		return nil, newAPIError(resp, apiResp.ErrorCode, apiResp.Description, apiResp.Parameters)
 	}
 
-	return nil
-}
-
-// SendPrivateMessage sends a private message to a user
-func (c *Client) SendPrivateMessage(ctx context.Context, userID string, msg *types.Message) error {
-	return c.SendMessage(ctx, msg, &types.SendOptions{
-		Targets: []types.Target{{ID: userID, ChatType: types.ChatTypePrivate}},
-	})
-}
-
-// SendGroupMessage sends a message to a group
-func (c *Client) SendGroupMessage(ctx context.Context, groupID string, msg *types.Message) error {
-	return c.SendMessage(ctx, msg, &types.SendOptions{
-		Targets: []types.Target{{ID: groupID, ChatType: types.ChatTypeGroup}},
-	})
+	return respBody, nil
 }
 
 // Close releases all resources held by the client
@@ -216,6 +370,7 @@ func (c *Client) Close() error {
 	}
 
 	c.closed = true
+	close(c.done)
 
 	// Close HTTP client connections if we own it
 	if c.ownsHTTP && c.httpClient != nil {
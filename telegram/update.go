@@ -0,0 +1,153 @@
+package telegram
+
+import (
+	"context"
+	"strconv"
+	"time"
+	"unicode/utf16"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+// Update is an incoming update from the Bot API.
+// 参考: https://core.telegram.org/bots/api#update
+type Update struct {
+	UpdateID          int64    `json:"update_id"`
+	Message           *Message `json:"message,omitempty"`
+	EditedMessage     *Message `json:"edited_message,omitempty"`
+	ChannelPost       *Message `json:"channel_post,omitempty"`
+	EditedChannelPost *Message `json:"edited_channel_post,omitempty"`
+
+	CallbackQuery *CallbackQuery `json:"callback_query,omitempty"`
+
+	raw []byte // Original JSON of the update
+}
+
+// Message is a Telegram message (only the fields parrot uses)
+type Message struct {
+	MessageID int64           `json:"message_id"`
+	From      *User           `json:"from,omitempty"`
+	Chat      Chat            `json:"chat"`
+	Date      int64           `json:"date"`
+	Text      string          `json:"text,omitempty"`
+	Caption   string          `json:"caption,omitempty"`
+	Entities  []MessageEntity `json:"entities,omitempty"`
+
+	CaptionEntities []MessageEntity `json:"caption_entities,omitempty"`
+}
+
+// User is a Telegram user or bot
+type User struct {
+	ID        int64  `json:"id"`
+	IsBot     bool   `json:"is_bot"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name,omitempty"`
+	Username  string `json:"username,omitempty"`
+}
+
+// Chat is a Telegram chat
+type Chat struct {
+	ID       int64  `json:"id"`
+	Type     string `json:"type"` // "private", "group", "supergroup" or "channel"
+	Title    string `json:"title,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+// MessageEntity is a special entity in a text message (mention, URL, ...).
+// Offset and Length are measured in UTF-16 code units.
+type MessageEntity struct {
+	Type   string `json:"type"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	URL    string `json:"url,omitempty"`
+	User   *User  `json:"user,omitempty"`
+}
+
+// UpdateHandler handles a single update.
+// Returning an error makes the webhook answer with HTTP 500 so Telegram redelivers it.
+type UpdateHandler func(ctx context.Context, update *Update) error
+
+// HandleMessages adapts a types.MessageHandler into an UpdateHandler.
+// New messages and channel posts are normalized and passed on, other updates are ignored.
+func HandleMessages(handler types.MessageHandler) UpdateHandler {
+	return func(ctx context.Context, update *Update) error {
+		msg := update.Message
+		if msg == nil {
+			msg = update.ChannelPost
+		}
+		if msg == nil {
+			return nil
+		}
+		incoming := msg.ToIncoming()
+		incoming.Raw = update.raw
+		return handler(ctx, incoming)
+	}
+}
+
+// displayName returns the user's full name
+func (u *User) displayName() string {
+	if u.LastName != "" {
+		return u.FirstName + " " + u.LastName
+	}
+	return u.FirstName
+}
+
+// ToIncoming normalizes the message into a types.IncomingMessage.
+// Raw is left empty, HandleMessages sets it to the original update.
+func (m *Message) ToIncoming() *types.IncomingMessage {
+	chatType := types.ChatTypeGroup
+	if m.Chat.Type == "private" {
+		chatType = types.ChatTypePrivate
+	}
+
+	text, entities := m.Text, m.Entities
+	if text == "" {
+		text, entities = m.Caption, m.CaptionEntities
+	}
+
+	incoming := &types.IncomingMessage{
+		Platform:  "telegram",
+		ChatType:  chatType,
+		ChatID:    strconv.FormatInt(m.Chat.ID, 10),
+		MessageID: strconv.FormatInt(m.MessageID, 10),
+		Text:      text,
+		Mentions:  mentions(text, entities),
+		Timestamp: time.Unix(m.Date, 0),
+	}
+	if m.From != nil {
+		incoming.Sender = types.Sender{
+			ID:    strconv.FormatInt(m.From.ID, 10),
+			Name:  m.From.displayName(),
+			IsBot: m.From.IsBot,
+		}
+	}
+	return incoming
+}
+
+// mentions extracts the "mention" (@username) and "text_mention" (users without
+// a username) entities of text
+func mentions(text string, entities []MessageEntity) []types.Mention {
+	var units []uint16
+	result := make([]types.Mention, 0)
+	for _, e := range entities {
+		switch e.Type {
+		case "mention":
+			if units == nil {
+				units = utf16.Encode([]rune(text))
+			}
+			if e.Offset < 0 || e.Length < 1 || e.Offset+e.Length > len(units) {
+				continue
+			}
+			name := string(utf16.Decode(units[e.Offset+1 : e.Offset+e.Length])) // Drop the leading '@'
+			result = append(result, types.Mention{Name: name})
+		case "text_mention":
+			if e.User != nil {
+				result = append(result, types.Mention{
+					ID:   strconv.FormatInt(e.User.ID, 10),
+					Name: e.User.displayName(),
+				})
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,53 @@
+package telegram
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// maxUpdateBody limits the size of a webhook update body
+const maxUpdateBody = 1 << 20
+
+// secretTokenHeader carries the secret_token passed to setWebhook
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+// NewWebhookHandler returns an http.Handler for the URL registered with setWebhook.
+// When secretToken is non-empty, requests without the matching
+// X-Telegram-Bot-Api-Secret-Token header are rejected.
+// Use HandleMessages to receive normalized types.IncomingMessage values.
+func NewWebhookHandler(secretToken string, handler UpdateHandler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if secretToken != "" &&
+			subtle.ConstantTimeCompare([]byte(r.Header.Get(secretTokenHeader)), []byte(secretToken)) != 1 {
+			http.Error(w, "invalid secret token", http.StatusUnauthorized)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxUpdateBody))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var update Update
+		if err := json.Unmarshal(body, &update); err != nil {
+			http.Error(w, "invalid update", http.StatusBadRequest)
+			return
+		}
+
+		update.raw = body
+		if err := handler(r.Context(), &update); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
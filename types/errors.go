@@ -0,0 +1,116 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ErrorKind classifies a platform API error by what the caller can do about it
+type ErrorKind int
+
+const (
+	ErrorKindUnknown        ErrorKind = iota // Not classified by the platform backend
+	ErrorKindRateLimited                     // Quota exceeded, retry later
+	ErrorKindAuth                            // Invalid credentials, signature or missing permission
+	ErrorKindTokenExpired                    // Access token expired or revoked, a refresh fixes it
+	ErrorKindInvalidTarget                   // Receiver doesn't exist or can't be reached by the bot
+	ErrorKindInvalidRequest                  // Malformed request or unsupported content
+	ErrorKindServer                          // Transient failure on the platform side
+)
+
+// String returns a readable name for the kind
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorKindRateLimited:
+		return "rate_limited"
+	case ErrorKindAuth:
+		return "auth"
+	case ErrorKindTokenExpired:
+		return "token_expired"
+	case ErrorKindInvalidTarget:
+		return "invalid_target"
+	case ErrorKindInvalidRequest:
+		return "invalid_request"
+	case ErrorKindServer:
+		return "server"
+	default:
+		return "unknown"
+	}
+}
+
+// APIError is an error reported by a platform API.
+// Use errors.As to inspect it, e.g. in SendError.FailedTargets.
+type APIError struct {
+	Platform   string        // Platform name, e.g. "lark"
+	HTTPStatus int           // HTTP status code of the response
+	Code       int           // Platform error code (errcode, code, error_code)
+	Message    string        // Platform error message
+	RequestID  string        // Request/log ID for support tickets, if the platform returns one
+	Kind       ErrorKind     // Classification derived from the platform code and HTTP status
+	RetryAfter time.Duration // Server hint on when to retry (e.g. Telegram's retry_after), 0 if none
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("%s API error (code %d, request %s): %s", e.Platform, e.Code, e.RequestID, e.Message)
+	}
+	return fmt.Sprintf("%s API error (code %d): %s", e.Platform, e.Code, e.Message)
+}
+
+// IsRateLimited reports whether the request was rejected by a quota
+func (e *APIError) IsRateLimited() bool {
+	return e.Kind == ErrorKindRateLimited || e.HTTPStatus == http.StatusTooManyRequests
+}
+
+// Is makes rate limited API errors match ErrRateLimited
+func (e *APIError) Is(target error) bool {
+	return target == ErrRateLimited && e.IsRateLimited()
+}
+
+// IsAuthError reports whether the credentials, token or permissions were rejected
+func (e *APIError) IsAuthError() bool {
+	return e.Kind == ErrorKindAuth || e.Kind == ErrorKindTokenExpired || e.HTTPStatus == http.StatusUnauthorized
+}
+
+// IsInvalidTarget reports whether the receiver can't be reached, so re-sending won't help
+func (e *APIError) IsInvalidTarget() bool {
+	return e.Kind == ErrorKindInvalidTarget
+}
+
+// IsRetryable reports whether the same request may succeed later
+func (e *APIError) IsRetryable() bool {
+	switch {
+	case e.IsRateLimited():
+		return true
+	case e.Kind == ErrorKindTokenExpired, e.Kind == ErrorKindServer:
+		return true
+	case e.Kind == ErrorKindUnknown:
+		return e.HTTPStatus >= http.StatusInternalServerError
+	default:
+		return false
+	}
+}
+
+// AsAPIError returns the first *APIError in err's chain
+func AsAPIError(err error) (*APIError, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr, true
+	}
+	return nil, false
+}
+
+// retryAfter returns the server's retry hint carried by err, if any
+func retryAfter(err error) time.Duration {
+	if apiErr, ok := AsAPIError(err); ok {
+		return apiErr.RetryAfter
+	}
+	var rateErr *RateLimitError
+	if errors.As(err, &rateErr) {
+		return rateErr.RetryAfter
+	}
+	return 0
+}
@@ -0,0 +1,86 @@
+package types
+
+import (
+	"context"
+	"sync"
+)
+
+// SendFunc delivers a message to a single target (including any retries)
+type SendFunc func(ctx context.Context, target Target) (*SendResult, error)
+
+// FanOut sends to every target with at most concurrency sends in flight
+// (values below 1 mean one at a time).
+//
+// Targets that share a Key are sent sequentially in the order they appear, so
+// repeated sends to the same chat keep their order. Results follow the order of
+// targets; failures are aggregated into a *SendError together with the
+// receipts of the successful targets.
+func FanOut(ctx context.Context, targets []Target, concurrency int, send SendFunc) ([]SendResult, error) {
+	// Group target indexes by key, keeping first-seen order
+	groups := make([][]int, 0, len(targets))
+	groupOf := make(map[string]int, len(targets))
+	for i, target := range targets {
+		key := target.Key()
+		g, ok := groupOf[key]
+		if !ok {
+			g = len(groups)
+			groupOf[key] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > len(groups) {
+		concurrency = len(groups)
+	}
+
+	results := make([]*SendResult, len(targets))
+	errs := make([]error, len(targets))
+
+	// Bounded worker pool, each worker drains whole groups
+	work := make(chan []int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range work {
+				for _, i := range group {
+					results[i], errs[i] = send(ctx, targets[i])
+				}
+			}
+		}()
+	}
+	for _, group := range groups {
+		work <- group
+	}
+	close(work)
+	wg.Wait()
+
+	sent := make([]SendResult, 0, len(targets))
+	failedTargets := make([]FailedTarget, 0)
+	for i, target := range targets {
+		if errs[i] != nil || results[i] == nil {
+			failedTargets = append(failedTargets, FailedTarget{
+				Target: target,
+				Error:  errs[i],
+			})
+			continue
+		}
+		sent = append(sent, *results[i])
+	}
+
+	// Return error with failed targets information
+	if len(failedTargets) > 0 {
+		return sent, &SendError{
+			FailedTargets: failedTargets,
+			SuccessCount:  len(sent),
+			TotalCount:    len(targets),
+		}
+	}
+
+	return sent, nil
+}
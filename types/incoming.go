@@ -0,0 +1,37 @@
+package types
+
+import (
+	"context"
+	"time"
+)
+
+// IncomingMessage is a message received from a platform, normalized across platforms
+type IncomingMessage struct {
+	Platform  string    // Platform name, e.g. "lark"
+	ChatType  ChatType  // Private or group chat
+	ChatID    string    // Chat the message was posted in (the sender's ID for private chats where the platform has no chat ID)
+	MessageID string    // Platform message ID
+	Sender    Sender    // Who sent the message
+	Text      string    // Plain text content, empty for non-text messages
+	Mentions  []Mention // Users mentioned in the message
+	Timestamp time.Time // Send time reported by the platform
+	Raw       []byte    // Raw (decrypted) payload as delivered by the platform
+}
+
+// Sender identifies the author of an incoming message
+type Sender struct {
+	ID    string // Platform user ID (open_id, user ID, staff ID, ...)
+	Name  string // Display name, if the platform provides one
+	IsBot bool   // Whether the sender is a bot
+}
+
+// Mention is a user mentioned in an incoming message
+type Mention struct {
+	ID   string // Platform user ID, empty if the platform only reports the name
+	Name string // Display name or username
+}
+
+// MessageHandler handles an incoming message.
+// Returning an error makes the receiving webhook answer with HTTP 500,
+// so platforms that redeliver failed callbacks will try again.
+type MessageHandler func(ctx context.Context, msg *IncomingMessage) error
@@ -0,0 +1,251 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// maxIdleBuckets is the number of per-target buckets kept before idle ones are pruned
+const maxIdleBuckets = 1024
+
+// RateLimit configures client-side rate limiting with token buckets.
+// Rates are in messages per second; a zero rate disables that bucket,
+// so &RateLimit{} turns rate limiting off.
+type RateLimit struct {
+	Rate  float64 // Messages per second across the whole client
+	Burst int     // Messages that may be sent at once before Rate applies (default: 1)
+
+	PerTargetRate  float64 // Messages per second to a single user or chat
+	PerTargetBurst int     // Burst for a single target (default: 1)
+
+	// FailFast returns a *RateLimitError instead of waiting for a token
+	FailFast bool
+}
+
+// PerMinute converts a per-minute quota into a per-second rate
+func PerMinute(n int) float64 {
+	return float64(n) / 60
+}
+
+// ErrRateLimited is matched (via errors.Is) by client-side rate limit errors
+// and by APIErrors the platform reported as rate limited
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimitError is returned by a fail-fast RateLimiter when no token is available
+type RateLimitError struct {
+	Platform   string        // Platform name
+	Target     string        // Target ID, empty when the client-wide limit was hit
+	RetryAfter time.Duration // Time until a token becomes available
+}
+
+// Error implements the error interface
+func (e *RateLimitError) Error() string {
+	if e.Target != "" {
+		return fmt.Sprintf("%s client-side rate limit exceeded for target %s, retry after %v", e.Platform, e.Target, e.RetryAfter)
+	}
+	return fmt.Sprintf("%s client-side rate limit exceeded, retry after %v", e.Platform, e.RetryAfter)
+}
+
+// Unwrap makes RateLimitError match ErrRateLimited
+func (e *RateLimitError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// IsRateLimited always reports true
+func (e *RateLimitError) IsRateLimited() bool {
+	return true
+}
+
+// IsRetryable reports false: the caller asked to fail fast instead of waiting
+func (e *RateLimitError) IsRetryable() bool {
+	return false
+}
+
+// RateLimiter enforces a RateLimit for one client: a client-wide bucket plus one
+// bucket per target. A nil *RateLimiter never limits. It is safe for concurrent use.
+type RateLimiter struct {
+	platform string
+	config   RateLimit
+	mu       sync.Mutex
+	global   *bucket
+	targets  map[string]*bucket
+}
+
+// NewRateLimiter creates a limiter for the given platform.
+// It returns nil (no limiting) when config is nil or sets no rate.
+func NewRateLimiter(platform string, config *RateLimit) *RateLimiter {
+	if config == nil || (config.Rate <= 0 && config.PerTargetRate <= 0) {
+		return nil
+	}
+
+	l := &RateLimiter{
+		platform: platform,
+		config:   *config,
+		targets:  make(map[string]*bucket),
+	}
+	if config.Rate > 0 {
+		l.global = newBucket(config.Rate, config.Burst)
+	}
+	return l
+}
+
+// Wait blocks until a message may be sent to target (empty for the client-wide
+// limit only), or returns ctx's error. With FailFast it returns a *RateLimitError
+// instead of blocking.
+func (l *RateLimiter) Wait(ctx context.Context, target string) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	buckets := l.bucketsFor(target, now)
+
+	var wait time.Duration
+	for _, b := range buckets {
+		if d := b.delay(now); d > wait {
+			wait = d
+		}
+	}
+
+	if wait > 0 && l.config.FailFast {
+		l.mu.Unlock()
+		return &RateLimitError{Platform: l.platform, Target: target, RetryAfter: wait}
+	}
+
+	// Reserve the tokens now so concurrent callers queue up behind us
+	for _, b := range buckets {
+		b.take(now)
+	}
+	l.mu.Unlock()
+
+	if err := sleepContext(ctx, wait); err != nil {
+		// Give the reservation back, the message won't be sent
+		l.mu.Lock()
+		for _, b := range buckets {
+			b.tokens = math.Min(b.tokens+1, b.burst)
+		}
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Pause blocks sends to target (or to the whole client when target is empty)
+// for d, e.g. after the platform returned a retry_after hint
+func (l *RateLimiter) Pause(target string, d time.Duration) {
+	if l == nil || d <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	until := now.Add(d)
+	var b *bucket
+	if target == "" {
+		b = l.global
+	} else {
+		buckets := l.bucketsFor(target, now)
+		b = buckets[len(buckets)-1]
+	}
+	if b != nil && until.After(b.pausedUntil) {
+		b.pausedUntil = until
+	}
+}
+
+// bucketsFor returns the buckets that apply to target, creating the target's
+// bucket on first use (or a standalone one so Pause works without PerTargetRate).
+// Must be called with l.mu held.
+func (l *RateLimiter) bucketsFor(target string, now time.Time) []*bucket {
+	buckets := make([]*bucket, 0, 2)
+	if l.global != nil {
+		buckets = append(buckets, l.global)
+	}
+	if target == "" {
+		return buckets
+	}
+
+	b, ok := l.targets[target]
+	if !ok {
+		if len(l.targets) >= maxIdleBuckets {
+			l.pruneIdle(now)
+		}
+		b = newBucket(l.config.PerTargetRate, l.config.PerTargetBurst)
+		l.targets[target] = b
+	}
+	return append(buckets, b)
+}
+
+// pruneIdle drops per-target buckets that are full again, they carry no state.
+// Must be called with l.mu held.
+func (l *RateLimiter) pruneIdle(now time.Time) {
+	for key, b := range l.targets {
+		b.refill(now)
+		if b.tokens >= b.burst && now.After(b.pausedUntil) {
+			delete(l.targets, key)
+		}
+	}
+}
+
+// bucket is a token bucket; a zero rate never runs out of tokens
+type bucket struct {
+	rate        float64
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+func newBucket(rate float64, burst int) *bucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &bucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// refill adds the tokens accumulated since the last update
+func (b *bucket) refill(now time.Time) {
+	if b.rate > 0 && now.After(b.last) {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+}
+
+// delay returns how long to wait until a token is available
+func (b *bucket) delay(now time.Time) time.Duration {
+	var wait time.Duration
+	if now.Before(b.pausedUntil) {
+		wait = b.pausedUntil.Sub(now)
+	}
+	if b.rate <= 0 {
+		return wait
+	}
+
+	b.refill(now)
+	if b.tokens < 1 {
+		if d := time.Duration((1 - b.tokens) / b.rate * float64(time.Second)); d > wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// take consumes one token; the balance may go negative to queue reservations
+func (b *bucket) take(now time.Time) {
+	if b.rate <= 0 {
+		return
+	}
+	b.refill(now)
+	b.tokens--
+}
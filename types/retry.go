@@ -0,0 +1,191 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy controls how a failed send to a single target is retried.
+// A nil *RetryPolicy behaves like DefaultRetryPolicy().
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	// Values below 1 mean a single attempt (no retry).
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the first retry
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the wait between two attempts (0 means no cap)
+	MaxBackoff time.Duration
+
+	// Multiplier grows the backoff after every attempt (values below 1 are treated as 1)
+	Multiplier float64
+
+	// Jitter randomizes each backoff by up to ±Jitter (0.2 = ±20%)
+	Jitter float64
+
+	// Retryable decides whether an error is worth retrying.
+	// Default: IsRetryable
+	Retryable func(err error) bool
+}
+
+// DefaultRetryPolicy returns the policy used when a config doesn't set one:
+// 3 attempts with exponential backoff starting at 100ms
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// NoRetry returns a policy that makes a single attempt
+func NoRetry() *RetryPolicy {
+	return &RetryPolicy{MaxAttempts: 1}
+}
+
+// Do calls fn until it succeeds, returns a non-retryable error, the attempts are
+// exhausted, or ctx is done. The last error from fn is returned.
+// A retry_after hint carried by the error takes precedence over a shorter backoff.
+func (p *RetryPolicy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if p == nil {
+		p = DefaultRetryPolicy()
+	}
+
+	attempts := p.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if err = fn(ctx); err == nil {
+			return nil
+		}
+
+		if attempt == attempts-1 || !retryable(err) {
+			return err
+		}
+
+		// Never retry earlier than the platform asked us to
+		wait := p.Backoff(attempt)
+		if hint := retryAfter(err); hint > wait {
+			wait = hint
+		}
+
+		if waitErr := sleepContext(ctx, wait); waitErr != nil {
+			return fmt.Errorf("%w (retry aborted: %v)", err, waitErr)
+		}
+	}
+
+	return err
+}
+
+// Backoff returns the wait after the given (zero-based) failed attempt
+func (p *RetryPolicy) Backoff(attempt int) time.Duration {
+	if p == nil {
+		p = DefaultRetryPolicy()
+	}
+
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	backoff := float64(p.InitialBackoff)
+	for i := 0; i < attempt; i++ {
+		backoff *= multiplier
+		if p.MaxBackoff > 0 && backoff >= float64(p.MaxBackoff) {
+			break
+		}
+	}
+
+	if p.Jitter > 0 {
+		backoff += backoff * p.Jitter * (2*rand.Float64() - 1)
+	}
+
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	if backoff < 0 {
+		backoff = 0
+	}
+
+	return time.Duration(backoff)
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// PermanentError marks an error that must not be retried
+type PermanentError struct {
+	Err error
+}
+
+// Permanent wraps err so that IsRetryable reports false for it
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// Error implements the error interface
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable always reports false for a permanent error
+func (e *PermanentError) IsRetryable() bool {
+	return false
+}
+
+// IsRetryable is the default retry classifier.
+// Context cancellation is never retried; errors that implement
+// interface{ IsRetryable() bool } decide for themselves; everything else
+// (network failures, malformed responses) is assumed to be transient.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var classified interface{ IsRetryable() bool }
+	if errors.As(err, &classified) {
+		return classified.IsRetryable()
+	}
+
+	return true
+}
@@ -0,0 +1,290 @@
+package types
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// LengthUnit is the unit a platform measures message length in
+type LengthUnit int
+
+const (
+	LengthBytes LengthUnit = iota // UTF-8 bytes
+	LengthRunes                   // Unicode code points
+	LengthUTF16                   // UTF-16 code units
+)
+
+// Len returns the length of s in the unit
+func (u LengthUnit) Len(s string) int {
+	switch u {
+	case LengthRunes:
+		return utf8.RuneCountInString(s)
+	case LengthUTF16:
+		n := 0
+		for _, r := range s {
+			n += len(utf16.Encode([]rune{r}))
+		}
+		return n
+	default:
+		return len(s)
+	}
+}
+
+// Limit is the maximum length of a message's content on a platform
+type Limit struct {
+	Max  int        // Maximum length, 0 for no limit
+	Unit LengthUnit // Unit Max is measured in
+}
+
+// SplitText splits text into parts no longer than limit. It breaks at paragraph
+// (blank line) boundaries first, then at line breaks, then at spaces; code blocks
+// are split between lines and re-fenced in every part. Spaces inside inline code,
+// bold, strikethrough and links aren't used as break points, but a line with no
+// other space that fits is cut at the limit, even inside an entity. UTF-8
+// sequences are never cut. Text within the limit is returned as the only part.
+func SplitText(text string, limit Limit) []string {
+	if limit.Max <= 0 || limit.Unit.Len(text) <= limit.Max {
+		return []string{text}
+	}
+
+	parts := make([]string, 0)
+	var current strings.Builder
+	currentLen := 0
+	flush := func() {
+		if part := strings.Trim(current.String(), "\n"); part != "" {
+			parts = append(parts, part)
+		}
+		current.Reset()
+		currentLen = 0
+	}
+
+	for _, block := range splitBlocks(text) {
+		for _, piece := range breakBlock(block, limit) {
+			pieceLen := limit.Unit.Len(piece)
+			if currentLen+pieceLen > limit.Max {
+				flush()
+			}
+			current.WriteString(piece)
+			currentLen += pieceLen
+		}
+	}
+	flush()
+
+	return parts
+}
+
+// textBlock is a paragraph or a fenced code block, with its trailing newlines
+type textBlock struct {
+	text  string
+	fence string // Opening fence line of a code block, e.g. "```go"
+}
+
+// splitBlocks splits text into paragraphs and fenced code blocks
+func splitBlocks(text string) []textBlock {
+	blocks := make([]textBlock, 0)
+	var current strings.Builder
+	fence := "" // Opening fence line of the current code block
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence != "":
+			current.WriteString(line)
+			marker := strings.TrimSpace(fence)[:3]
+			if strings.HasPrefix(trimmed, marker) && strings.Trim(trimmed, marker[:1]) == "" {
+				blocks = append(blocks, textBlock{text: current.String(), fence: fence})
+				current.Reset()
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			// A code block starts: close the paragraph before it
+			if current.Len() > 0 {
+				blocks = append(blocks, textBlock{text: current.String()})
+				current.Reset()
+			}
+			fence = strings.TrimRight(line, "\r\n")
+			current.WriteString(line)
+		case trimmed == "":
+			current.WriteString(line)
+			blocks = append(blocks, textBlock{text: current.String()})
+			current.Reset()
+		default:
+			current.WriteString(line)
+		}
+	}
+
+	// An unclosed code block runs to the end of the text
+	if current.Len() > 0 {
+		blocks = append(blocks, textBlock{text: current.String(), fence: fence})
+	}
+	return blocks
+}
+
+// breakBlock breaks a block longer than limit into pieces that fit
+func breakBlock(block textBlock, limit Limit) []string {
+	if limit.Unit.Len(block.text) <= limit.Max {
+		return []string{block.text}
+	}
+
+	lines := strings.SplitAfter(block.text, "\n")
+	if block.fence == "" {
+		pieces := make([]string, 0, len(lines))
+		for _, line := range lines {
+			pieces = append(pieces, splitLine(line, limit.Max, limit.Unit)...)
+		}
+		return pieces
+	}
+
+	// Split the code between lines, closing and reopening the fence around every piece
+	open := block.fence + "\n"
+	closing := strings.TrimSpace(block.fence)[:3] + "\n"
+	room := limit.Max - limit.Unit.Len(open) - limit.Unit.Len(closing)
+	if room <= 0 {
+		return splitLine(block.text, limit.Max, limit.Unit)
+	}
+
+	// Drop the empty element SplitAfter leaves after a final newline, then the closing fence
+	code := lines[1:]
+	for len(code) > 0 && code[len(code)-1] == "" {
+		code = code[:len(code)-1]
+	}
+	if n := len(code); n > 0 && strings.HasPrefix(strings.TrimSpace(code[n-1]), closing[:3]) {
+		code = code[:n-1]
+	}
+
+	pieces := make([]string, 0)
+	var current strings.Builder
+	currentLen := 0
+	for _, line := range code {
+		for _, chunk := range hardSplit(line, room, limit.Unit) {
+			chunkLen := limit.Unit.Len(chunk)
+			if currentLen+chunkLen > room && current.Len() > 0 {
+				pieces = append(pieces, open+current.String()+closing)
+				current.Reset()
+				currentLen = 0
+			}
+			current.WriteString(chunk)
+			currentLen += chunkLen
+		}
+	}
+	if current.Len() > 0 {
+		pieces = append(pieces, open+current.String()+closing)
+	}
+	return pieces
+}
+
+// splitLine splits a line longer than max at spaces outside inline entities.
+// When there is no such space, the line is cut at the last rune boundary within
+// max, which may fall inside an entity.
+func splitLine(line string, max int, unit LengthUnit) []string {
+	pieces := make([]string, 0)
+	for unit.Len(line) > max {
+		// Longest prefix within max, ending on a rune boundary (at least one rune)
+		end, n := 0, 0
+		for i, r := range line {
+			n += unit.Len(string(r))
+			if n > max && end > 0 {
+				break
+			}
+			end = i + utf8.RuneLen(r)
+		}
+
+		cut := lastBreak(line[:end])
+		if cut == 0 {
+			cut = end
+		}
+		// The space the line is broken at becomes the line break
+		pieces = append(pieces, strings.TrimSuffix(line[:cut], " ")+"\n")
+		line = line[cut:]
+	}
+	return append(pieces, line)
+}
+
+// hardSplit splits s into chunks of at most max at rune boundaries
+func hardSplit(s string, max int, unit LengthUnit) []string {
+	chunks := make([]string, 0, 1)
+	start, n := 0, 0
+	for i, r := range s {
+		rl := unit.Len(string(r))
+		if n+rl > max && i > start {
+			chunks = append(chunks, s[start:i])
+			start, n = i, 0
+		}
+		n += rl
+	}
+	return append(chunks, s[start:])
+}
+
+// lastBreak returns the index after the last space of s that is outside inline
+// entities (`code`, **bold**, ~~strikethrough~~ and [links](url)), or 0
+func lastBreak(s string) int {
+	inCode, bold, strike, inURL := false, false, false, false
+	brackets := 0
+	last := 0
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '`':
+			inCode = !inCode
+		case inCode:
+		case c == '*' && i+1 < len(s) && s[i+1] == '*':
+			bold = !bold
+			i++
+		case c == '~' && i+1 < len(s) && s[i+1] == '~':
+			strike = !strike
+			i++
+		case c == '[':
+			brackets++
+		case c == ']':
+			if brackets > 0 {
+				brackets--
+			}
+			if i+1 < len(s) && s[i+1] == '(' {
+				inURL = true
+				i++
+			}
+		case c == ')' && inURL:
+			inURL = false
+		case c == ' ' && !bold && !strike && !inURL && brackets == 0:
+			last = i + 1
+		}
+	}
+	return last
+}
+
+// SendSplitFunc delivers one message (or one part of it) to a single target, including retries
+type SendSplitFunc func(ctx context.Context, msg *Message) (*SendResult, error)
+
+// SendSplit delivers msg with send. When opts.Split is set and a text or markdown
+// message exceeds limit, its content is split with SplitText and the parts are sent
+// in order, stopping at the first failure. The returned receipt describes the first
+// part and lists every part in Parts.
+func SendSplit(ctx context.Context, msg *Message, opts *SendOptions, limit Limit, send SendSplitFunc) (*SendResult, error) {
+	if !opts.Split || (msg.Type != MessageTypeText && msg.Type != MessageTypeMarkdown) {
+		return send(ctx, msg)
+	}
+
+	contents := SplitText(msg.Content, limit)
+	if len(contents) == 1 {
+		return send(ctx, msg)
+	}
+
+	parts := make([]SendResult, 0, len(contents))
+	for i, content := range contents {
+		part := *msg
+		part.Content = content
+		result, err := send(ctx, &part)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send part %d/%d (%d delivered): %w", i+1, len(contents), len(parts), err)
+		}
+		parts = append(parts, *result)
+	}
+
+	result := parts[0]
+	result.Parts = parts
+	return &result, nil
+}
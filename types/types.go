@@ -2,7 +2,10 @@ package types
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 )
 
 // MessageType defines the type of message
@@ -44,11 +47,38 @@ type Target struct {
 	ChatType ChatType // Private or group chat
 }
 
+// Key returns a string that identifies the target across chat types,
+// e.g. for per-target rate limiting
+func (t Target) Key() string {
+	return string(t.ChatType) + ":" + t.ID
+}
+
 // SendOptions contains options for sending messages
 type SendOptions struct {
 	Targets []Target               // Multiple targets with their chat types
 	AtUsers []string               // Users to @ mention (for group messages)
 	Extra   map[string]interface{} // Platform-specific extra options
+
+	// Concurrency overrides the client's fan-out concurrency for this call (0 = client default)
+	Concurrency int
+
+	// Split sends text and markdown messages longer than the platform limit as
+	// several messages, in order, instead of letting the platform reject them.
+	// Platforms without a known limit return an UnsupportedError.
+	Split bool
+}
+
+// SendResult is the receipt of a message delivered to a single target
+type SendResult struct {
+	Target    Target          // The target the message was sent to
+	MessageID string          // Platform message ID (empty if the platform doesn't return one)
+	ChatID    string          // Chat the message landed in, as reported by the platform
+	Timestamp time.Time       // Send time reported by the platform, or local time if unavailable
+	Raw       json.RawMessage // Raw response body returned by the platform
+
+	// Parts holds the receipts of every part when the message was split (see
+	// SendOptions.Split); the fields above then describe the first part
+	Parts []SendResult
 }
 
 // FailedTarget represents a target that failed to receive a message
@@ -83,6 +113,11 @@ type IMParrot interface {
 	// SendMessage sends a message with options
 	SendMessage(ctx context.Context, msg *Message, opts *SendOptions) error
 
+	// SendMessageWithResult sends a message and returns a receipt for every target
+	// that received it. When some targets fail, the receipts of the successful ones
+	// are returned together with a *SendError.
+	SendMessageWithResult(ctx context.Context, msg *Message, opts *SendOptions) ([]SendResult, error)
+
 	// SendPrivateMessage sends a private message to a user
 	SendPrivateMessage(ctx context.Context, userID string, msg *Message) error
 
@@ -97,6 +132,38 @@ type IMParrot interface {
 	Close() error
 }
 
+// MessageEditor is an optional capability implemented by clients that can change
+// or withdraw messages after they have been sent. Use a type assertion on an
+// IMParrot to discover it. The receipt identifies the message; callers that only
+// persisted IDs can build one from MessageID and ChatID.
+type MessageEditor interface {
+	// UpdateMessage replaces the content of a sent message in place
+	UpdateMessage(ctx context.Context, receipt *SendResult, msg *Message) error
+
+	// RecallMessage withdraws a sent message
+	RecallMessage(ctx context.Context, receipt *SendResult) error
+}
+
+// ErrUnsupported is matched (via errors.Is) by errors returned when a platform
+// cannot perform the requested operation
+var ErrUnsupported = errors.New("operation not supported")
+
+// UnsupportedError reports an operation the platform does not support
+type UnsupportedError struct {
+	Platform  string // Platform name
+	Operation string // The unsupported operation, e.g. "UpdateMessage"
+}
+
+// Error implements the error interface
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("%s does not support %s", e.Platform, e.Operation)
+}
+
+// Unwrap makes UnsupportedError match ErrUnsupported
+func (e *UnsupportedError) Unwrap() error {
+	return ErrUnsupported
+}
+
 // Config is the interface for platform configurations
 type Config interface {
 	Validate() error
@@ -0,0 +1,321 @@
+package types_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+// TestRetryPolicy tests attempts, permanent errors and context cancellation
+func TestRetryPolicy(t *testing.T) {
+	policy := &types.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	transient := errors.New("connection reset")
+
+	t.Run("retries transient errors", func(t *testing.T) {
+		calls := 0
+		err := policy.Do(context.Background(), func(ctx context.Context) error {
+			calls++
+			return transient
+		})
+		if !errors.Is(err, transient) || calls != 3 {
+			t.Errorf("Do() = %v after %d calls, want transient error after 3", err, calls)
+		}
+	})
+
+	t.Run("stops on permanent errors", func(t *testing.T) {
+		calls := 0
+		err := policy.Do(context.Background(), func(ctx context.Context) error {
+			calls++
+			return types.Permanent(errors.New("invalid receive_id"))
+		})
+		if err == nil || calls != 1 {
+			t.Errorf("Do() = %v after %d calls, want error after 1", err, calls)
+		}
+	})
+
+	t.Run("honours context cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		slow := &types.RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour}
+		calls := 0
+		err := slow.Do(ctx, func(ctx context.Context) error {
+			calls++
+			cancel()
+			return transient
+		})
+		if !errors.Is(err, transient) || calls != 1 {
+			t.Errorf("Do() = %v after %d calls, want transient error after 1", err, calls)
+		}
+	})
+
+	t.Run("backoff is capped", func(t *testing.T) {
+		p := &types.RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+		if got := p.Backoff(0); got != 100*time.Millisecond {
+			t.Errorf("Backoff(0) = %v, want 100ms", got)
+		}
+		if got := p.Backoff(10); got != time.Second {
+			t.Errorf("Backoff(10) = %v, want 1s", got)
+		}
+	})
+}
+
+// TestAPIError tests classification helpers and errors.As through SendError
+func TestAPIError(t *testing.T) {
+	rateLimited := &types.APIError{Platform: "lark", HTTPStatus: 429, Code: 99991400, Kind: types.ErrorKindRateLimited}
+	invalidTarget := &types.APIError{Platform: "lark", HTTPStatus: 400, Code: 230002, Kind: types.ErrorKindInvalidTarget}
+	tokenExpired := &types.APIError{Platform: "wechat", HTTPStatus: 200, Code: 42001, Kind: types.ErrorKindTokenExpired}
+
+	if !rateLimited.IsRateLimited() || !rateLimited.IsRetryable() {
+		t.Error("rate limited error should be retryable")
+	}
+	if !invalidTarget.IsInvalidTarget() || invalidTarget.IsRetryable() || types.IsRetryable(invalidTarget) {
+		t.Error("invalid target error should not be retryable")
+	}
+	if !tokenExpired.IsAuthError() || !tokenExpired.IsRetryable() {
+		t.Error("token expired error should be an auth error worth retrying")
+	}
+
+	var err error = &types.SendError{
+		FailedTargets: []types.FailedTarget{{Target: types.Target{ID: "oc_1"}, Error: invalidTarget}},
+		TotalCount:    1,
+	}
+	var sendErr *types.SendError
+	if !errors.As(err, &sendErr) {
+		t.Fatal("expected a SendError")
+	}
+	apiErr, ok := types.AsAPIError(sendErr.FailedTargets[0].Error)
+	if !ok || apiErr.Code != 230002 {
+		t.Errorf("AsAPIError() = %v, %v", apiErr, ok)
+	}
+}
+
+// TestRateLimiter tests waiting, fail-fast and server pause hints
+func TestRateLimiter(t *testing.T) {
+	t.Run("nil config disables limiting", func(t *testing.T) {
+		if l := types.NewRateLimiter("test", &types.RateLimit{}); l != nil {
+			t.Error("expected nil limiter for empty config")
+		}
+		var l *types.RateLimiter
+		if err := l.Wait(context.Background(), "x"); err != nil {
+			t.Errorf("nil limiter Wait() = %v", err)
+		}
+	})
+
+	t.Run("per target fail fast", func(t *testing.T) {
+		l := types.NewRateLimiter("test", &types.RateLimit{PerTargetRate: 1, PerTargetBurst: 1, FailFast: true})
+		ctx := context.Background()
+		if err := l.Wait(ctx, "a"); err != nil {
+			t.Fatalf("first Wait() = %v", err)
+		}
+		err := l.Wait(ctx, "a")
+		if !errors.Is(err, types.ErrRateLimited) || types.IsRetryable(err) {
+			t.Errorf("second Wait() = %v, want non-retryable ErrRateLimited", err)
+		}
+		if err := l.Wait(ctx, "b"); err != nil {
+			t.Errorf("other target Wait() = %v", err)
+		}
+	})
+
+	t.Run("waits for a token", func(t *testing.T) {
+		l := types.NewRateLimiter("test", &types.RateLimit{Rate: 20, Burst: 1})
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			if err := l.Wait(context.Background(), ""); err != nil {
+				t.Fatalf("Wait() = %v", err)
+			}
+		}
+		// The burst covers the first send, the 2 others wait 50ms each: 100ms in all.
+		// Allow 10ms of timer slack below and 150ms of scheduling delay above.
+		if elapsed := time.Since(start); elapsed < 90*time.Millisecond || elapsed > 250*time.Millisecond {
+			t.Errorf("3 sends at 20/s took %v, want 90ms-250ms", elapsed)
+		}
+	})
+
+	t.Run("pause respects context", func(t *testing.T) {
+		l := types.NewRateLimiter("test", &types.RateLimit{Rate: 1000, Burst: 10})
+		l.Pause("", time.Hour)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if err := l.Wait(ctx, "a"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Wait() = %v, want deadline exceeded", err)
+		}
+	})
+
+	t.Run("rate limited API errors match ErrRateLimited", func(t *testing.T) {
+		err := error(&types.APIError{Platform: "telegram", HTTPStatus: 429, Code: 429})
+		if !errors.Is(err, types.ErrRateLimited) {
+			t.Error("expected APIError with 429 to match ErrRateLimited")
+		}
+	})
+}
+
+// TestFanOut tests bounded concurrency, per-target ordering and error aggregation
+func TestFanOut(t *testing.T) {
+	targets := []types.Target{
+		{ID: "a", ChatType: types.ChatTypeGroup},
+		{ID: "b", ChatType: types.ChatTypeGroup},
+		{ID: "a", ChatType: types.ChatTypeGroup},
+		{ID: "bad", ChatType: types.ChatTypePrivate},
+		{ID: "c", ChatType: types.ChatTypeGroup},
+	}
+
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	var orderA []int
+	calls := 0
+
+	results, err := types.FanOut(context.Background(), targets, 2, func(ctx context.Context, target types.Target) (*types.SendResult, error) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		calls++
+		if target.ID == "a" {
+			orderA = append(orderA, calls)
+		}
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+
+		if target.ID == "bad" {
+			return nil, types.Permanent(errors.New("invalid receive_id"))
+		}
+		return &types.SendResult{Target: target, MessageID: "m-" + target.ID}, nil
+	})
+
+	if maxInFlight > 2 {
+		t.Errorf("max in flight = %d, want <= 2", maxInFlight)
+	}
+	if len(orderA) != 2 || orderA[0] > orderA[1] {
+		t.Errorf("sends to the same target ran out of order: %v", orderA)
+	}
+
+	var sendErr *types.SendError
+	if !errors.As(err, &sendErr) {
+		t.Fatalf("FanOut() error = %v, want *SendError", err)
+	}
+	if sendErr.SuccessCount != 4 || sendErr.TotalCount != 5 || len(sendErr.FailedTargets) != 1 {
+		t.Errorf("unexpected SendError: %+v", sendErr)
+	}
+	if sendErr.FailedTargets[0].Target.ID != "bad" {
+		t.Errorf("failed target = %v, want bad", sendErr.FailedTargets[0].Target)
+	}
+
+	wantIDs := []string{"m-a", "m-b", "m-a", "m-c"}
+	if len(results) != len(wantIDs) {
+		t.Fatalf("got %d results, want %d", len(results), len(wantIDs))
+	}
+	for i, id := range wantIDs {
+		if results[i].MessageID != id {
+			t.Errorf("results[%d] = %s, want %s", i, results[i].MessageID, id)
+		}
+	}
+}
+
+// TestSplitText tests splitting at paragraph, line and space boundaries without
+// breaking code blocks, inline entities or multi-byte characters
+func TestSplitText(t *testing.T) {
+	t.Run("fits", func(t *testing.T) {
+		parts := types.SplitText("short", types.Limit{Max: 10})
+		if len(parts) != 1 || parts[0] != "short" {
+			t.Errorf("SplitText() = %q, want the text unchanged", parts)
+		}
+	})
+
+	t.Run("paragraphs", func(t *testing.T) {
+		text := strings.Repeat("a", 30) + "\n\n" + strings.Repeat("b", 30) + "\n\n" + strings.Repeat("c", 10)
+		parts := types.SplitText(text, types.Limit{Max: 50})
+		want := []string{strings.Repeat("a", 30), strings.Repeat("b", 30) + "\n\n" + strings.Repeat("c", 10)}
+		if strings.Join(parts, "|") != strings.Join(want, "|") {
+			t.Errorf("SplitText() = %q, want %q", parts, want)
+		}
+	})
+
+	t.Run("entities", func(t *testing.T) {
+		parts := types.SplitText("see **very important** now and `a b c` later", types.Limit{Max: 20})
+		for _, part := range parts {
+			if strings.Count(part, "**")%2 != 0 || strings.Count(part, "`")%2 != 0 {
+				t.Errorf("part %q cuts an entity in half (parts: %q)", part, parts)
+			}
+		}
+	})
+
+	t.Run("code block", func(t *testing.T) {
+		text := "```go\n" + strings.Repeat("fmt.Println(1)\n", 6) + "```"
+		parts := types.SplitText(text, types.Limit{Max: 40})
+		if len(parts) < 2 {
+			t.Fatalf("SplitText() = %q, want several parts", parts)
+		}
+		for _, part := range parts {
+			if !strings.HasPrefix(part, "```go\n") || !strings.HasSuffix(part, "```") || len(part) > 40 {
+				t.Errorf("part %q is not a fenced code block within the limit", part)
+			}
+		}
+	})
+
+	t.Run("code block followed by text", func(t *testing.T) {
+		text := "```\n" + strings.Repeat("fmt.Println(1)\n", 6) + "```\n\nDone."
+		parts := types.SplitText(text, types.Limit{Max: 40})
+		if len(parts) < 2 || parts[len(parts)-1] != "Done." {
+			t.Fatalf("SplitText() = %q, want code parts and the text", parts)
+		}
+		for _, part := range parts[:len(parts)-1] {
+			if !strings.HasPrefix(part, "```\n") || !strings.HasSuffix(part, "```") || strings.Count(part, "```") != 2 {
+				t.Errorf("part %q is not a single fenced code block", part)
+			}
+		}
+	})
+
+	t.Run("multi-byte", func(t *testing.T) {
+		text := strings.Repeat("告警", 10)
+		for _, limit := range []types.Limit{{Max: 7, Unit: types.LengthBytes}, {Max: 3, Unit: types.LengthRunes}, {Max: 3, Unit: types.LengthUTF16}} {
+			parts := types.SplitText(text, limit)
+			if strings.Join(parts, "") != text {
+				t.Errorf("parts %q don't add up to the text", parts)
+			}
+			for _, part := range parts {
+				if !utf8.ValidString(part) || limit.Unit.Len(part) > limit.Max {
+					t.Errorf("part %q is invalid or over %d", part, limit.Max)
+				}
+			}
+		}
+	})
+}
+
+// TestSendSplit tests that split parts are sent in order and reported as one result
+func TestSendSplit(t *testing.T) {
+	msg := &types.Message{Type: types.MessageTypeText, Content: "one two three four"}
+	var sent []string
+	send := func(ctx context.Context, msg *types.Message) (*types.SendResult, error) {
+		sent = append(sent, msg.Content)
+		return &types.SendResult{MessageID: strconv.Itoa(len(sent))}, nil
+	}
+
+	result, err := types.SendSplit(context.Background(), msg, &types.SendOptions{}, types.Limit{Max: 9}, send)
+	if err != nil || len(sent) != 1 || result.Parts != nil {
+		t.Fatalf("SendSplit() without Split = %+v, %v after %q", result, err, sent)
+	}
+
+	sent = nil
+	result, err = types.SendSplit(context.Background(), msg, &types.SendOptions{Split: true}, types.Limit{Max: 9}, send)
+	if err != nil {
+		t.Fatalf("SendSplit() error = %v", err)
+	}
+	if strings.Join(sent, "|") != "one two|three|four" {
+		t.Errorf("sent %q, want the parts in order", sent)
+	}
+	if result.MessageID != "1" || len(result.Parts) != 3 || result.Parts[2].MessageID != "3" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
@@ -0,0 +1,81 @@
+package wechat
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// AppChat is a group chat created by the app (群聊会话). Messages are sent to it
+// with a ChatTypeAppChat target, or a types.ChatTypeGroup target when
+// Config.AppChatGroups is set.
+// 参考: https://developer.work.weixin.qq.com/document/path/90245
+type AppChat struct {
+	ChatID   string   `json:"chatid,omitempty"` // Optional on create: 0-9 and a-zA-Z, up to 32 characters, generated if empty
+	Name     string   `json:"name,omitempty"`   // Optional: group name, up to 50 characters
+	Owner    string   `json:"owner,omitempty"`  // Optional: userid of the owner, a random member if empty
+	UserList []string `json:"userlist"`         // userids of the members, 2-2000 on create
+}
+
+// AppChatUpdate changes an app group chat, empty fields are left as they are
+type AppChatUpdate struct {
+	Name        string   // New group name
+	Owner       string   // userid of the new owner
+	AddUsers    []string // userids of the members to add
+	RemoveUsers []string // userids of the members to remove
+}
+
+// CreateAppChat creates a group chat (appchat/create) and returns its chat ID
+func (c *Client) CreateAppChat(ctx context.Context, chat *AppChat) (string, error) {
+	if chat == nil || len(chat.UserList) < 2 {
+		return "", fmt.Errorf("group chat requires at least 2 members")
+	}
+
+	var resp struct {
+		ChatID string `json:"chatid"`
+	}
+	if _, err := c.callAPI(ctx, http.MethodPost, appChatCreate, nil, chat, &resp); err != nil {
+		return "", err
+	}
+	return resp.ChatID, nil
+}
+
+// UpdateAppChat renames a group chat, changes its owner or adds and removes members (appchat/update)
+func (c *Client) UpdateAppChat(ctx context.Context, chatID string, update *AppChatUpdate) error {
+	if chatID == "" || update == nil {
+		return fmt.Errorf("chat ID and update cannot be empty")
+	}
+
+	reqBody := map[string]interface{}{"chatid": chatID}
+	if update.Name != "" {
+		reqBody["name"] = update.Name
+	}
+	if update.Owner != "" {
+		reqBody["owner"] = update.Owner
+	}
+	if len(update.AddUsers) > 0 {
+		reqBody["add_user_list"] = update.AddUsers
+	}
+	if len(update.RemoveUsers) > 0 {
+		reqBody["del_user_list"] = update.RemoveUsers
+	}
+
+	_, err := c.callAPI(ctx, http.MethodPost, appChatUpdate, nil, reqBody, nil)
+	return err
+}
+
+// GetAppChat returns the name, owner and members of a group chat (appchat/get)
+func (c *Client) GetAppChat(ctx context.Context, chatID string) (*AppChat, error) {
+	if chatID == "" {
+		return nil, fmt.Errorf("chat ID cannot be empty")
+	}
+
+	var resp struct {
+		ChatInfo AppChat `json:"chat_info"`
+	}
+	if _, err := c.callAPI(ctx, http.MethodGet, appChatGet, url.Values{"chatid": {chatID}}, nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp.ChatInfo, nil
+}
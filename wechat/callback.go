@@ -0,0 +1,130 @@
+package wechat
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+// maxCallbackBody limits the size of a callback body
+const maxCallbackBody = 1 << 20
+
+// encryptedEnvelope is the XML body posted to the callback URL
+type encryptedEnvelope struct {
+	ToUserName string `xml:"ToUserName"`
+	AgentID    string `xml:"AgentID"`
+	Encrypt    string `xml:"Encrypt"`
+}
+
+// callbackMessage is a decrypted message pushed to an app.
+// 参考: https://developer.work.weixin.qq.com/document/path/90239
+type callbackMessage struct {
+	ToUserName   string `xml:"ToUserName"`
+	FromUserName string `xml:"FromUserName"`
+	CreateTime   int64  `xml:"CreateTime"` // Seconds since epoch
+	MsgType      string `xml:"MsgType"`
+	Content      string `xml:"Content"`
+	MsgID        string `xml:"MsgId"`
+	AgentID      int    `xml:"AgentID"`
+}
+
+// toIncoming normalizes the message into a types.IncomingMessage.
+// Members talk to an app one-to-one, so the chat is the sender.
+func (m *callbackMessage) toIncoming(raw []byte) *types.IncomingMessage {
+	timestamp := time.Now()
+	if m.CreateTime > 0 {
+		timestamp = time.Unix(m.CreateTime, 0)
+	}
+
+	return &types.IncomingMessage{
+		Platform:  "wechat",
+		ChatType:  types.ChatTypePrivate,
+		ChatID:    m.FromUserName,
+		MessageID: m.MsgID,
+		Sender:    types.Sender{ID: m.FromUserName},
+		Text:      m.Content,
+		Mentions:  []types.Mention{},
+		Timestamp: timestamp,
+		Raw:       raw,
+	}
+}
+
+// NewCallbackHandler returns an http.Handler for an app's message receiving URL,
+// configured with the Token and EncodingAESKey set in the admin console.
+// GET requests answer the URL verification; POST requests are verified, decrypted
+// and text messages passed to handler. Events and other message types are
+// acknowledged and ignored.
+func NewCallbackHandler(token, encodingAESKey, corpID string, handler types.MessageHandler) (http.Handler, error) {
+	crypt, err := newMsgCrypt(token, encodingAESKey, corpID)
+	if err != nil {
+		return nil, err
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		msgSignature := query.Get("msg_signature")
+		timestamp := query.Get("timestamp")
+		nonce := query.Get("nonce")
+
+		switch r.Method {
+		case http.MethodGet:
+			// URL verification: echo the decrypted echostr
+			echostr := query.Get("echostr")
+			if !crypt.verify(msgSignature, timestamp, nonce, echostr) {
+				http.Error(w, "invalid signature", http.StatusUnauthorized)
+				return
+			}
+			plaintext, err := crypt.decrypt(echostr)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write(plaintext)
+
+		case http.MethodPost:
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxCallbackBody))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			var envelope encryptedEnvelope
+			if err := xml.Unmarshal(body, &envelope); err != nil {
+				http.Error(w, "invalid callback body", http.StatusBadRequest)
+				return
+			}
+			if !crypt.verify(msgSignature, timestamp, nonce, envelope.Encrypt) {
+				http.Error(w, "invalid signature", http.StatusUnauthorized)
+				return
+			}
+
+			plaintext, err := crypt.decrypt(envelope.Encrypt)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			var msg callbackMessage
+			if err := xml.Unmarshal(plaintext, &msg); err != nil {
+				http.Error(w, "invalid callback message", http.StatusBadRequest)
+				return
+			}
+
+			if msg.MsgType == "text" {
+				if err := handler(r.Context(), msg.toIncoming(plaintext)); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+
+			// An empty response tells WeChat not to reply passively
+			w.WriteHeader(http.StatusOK)
+
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}), nil
+}
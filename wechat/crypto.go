@@ -0,0 +1,111 @@
+package wechat
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// msgCrypt implements the WXBizMsgCrypt scheme used by callback URLs.
+// 参考: https://developer.work.weixin.qq.com/document/path/90968
+type msgCrypt struct {
+	token  string
+	key    []byte
+	corpID string
+}
+
+// newMsgCrypt creates a msgCrypt from the callback's Token and EncodingAESKey
+func newMsgCrypt(token, encodingAESKey, corpID string) (*msgCrypt, error) {
+	if token == "" {
+		return nil, fmt.Errorf("token is required")
+	}
+	if len(encodingAESKey) != 43 {
+		return nil, fmt.Errorf("EncodingAESKey must be 43 characters")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encodingAESKey + "=")
+	if err != nil {
+		return nil, fmt.Errorf("invalid EncodingAESKey: %w", err)
+	}
+
+	return &msgCrypt{token: token, key: key, corpID: corpID}, nil
+}
+
+// signature returns sha1 of the sorted concatenation of token, timestamp, nonce and encrypted
+func (m *msgCrypt) signature(timestamp, nonce, encrypted string) string {
+	parts := []string{m.token, timestamp, nonce, encrypted}
+	sort.Strings(parts)
+	sum := sha1.Sum([]byte(strings.Join(parts, "")))
+	return hex.EncodeToString(sum[:])
+}
+
+// verify checks msg_signature against the encrypted payload
+func (m *msgCrypt) verify(msgSignature, timestamp, nonce, encrypted string) bool {
+	expected := m.signature(timestamp, nonce, encrypted)
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(msgSignature)) == 1
+}
+
+// decrypt decrypts a payload laid out as random(16) + msg_len(4, big endian) + msg + receiveid
+// and checks that the receiveid matches the corp ID
+func (m *msgCrypt) decrypt(encrypted string) ([]byte, error) {
+	buf, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypted payload: %w", err)
+	}
+	if len(buf) == 0 || len(buf)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("invalid encrypted payload length %d", len(buf))
+	}
+
+	block, err := aes.NewCipher(m.key)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := make([]byte, len(buf))
+	cipher.NewCBCDecrypter(block, m.key[:aes.BlockSize]).CryptBlocks(plaintext, buf)
+
+	// WeChat pads to 32 bytes, not the AES block size
+	plaintext, err = pkcs7Unpad(plaintext, 32)
+	if err != nil {
+		return nil, err
+	}
+	if len(plaintext) < 20 {
+		return nil, fmt.Errorf("decrypted payload too short")
+	}
+
+	msgLen := int(binary.BigEndian.Uint32(plaintext[16:20]))
+	if msgLen > len(plaintext)-20 {
+		return nil, fmt.Errorf("invalid message length %d", msgLen)
+	}
+
+	msg, receiveID := plaintext[20:20+msgLen], string(plaintext[20+msgLen:])
+	if m.corpID != "" && receiveID != m.corpID {
+		return nil, fmt.Errorf("payload was encrypted for %q, not %q", receiveID, m.corpID)
+	}
+
+	return msg, nil
+}
+
+// pkcs7Unpad strips PKCS#7 padding
+func pkcs7Unpad(data []byte, blockSize int) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty plaintext")
+	}
+	pad := int(data[len(data)-1])
+	if pad == 0 || pad > blockSize || pad > len(data) {
+		return nil, fmt.Errorf("invalid padding")
+	}
+	for _, b := range data[len(data)-pad:] {
+		if int(b) != pad {
+			return nil, fmt.Errorf("invalid padding")
+		}
+	}
+	return data[:len(data)-pad], nil
+}
@@ -0,0 +1,60 @@
+package wechat
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+// errorKinds maps WeChat Work errcodes to error kinds
+// 参考: https://developer.work.weixin.qq.com/document/path/90313
+var errorKinds = map[int]types.ErrorKind{
+	-1:     types.ErrorKindServer,         // system busy
+	45009:  types.ErrorKindRateLimited,    // api freq out of limit
+	45033:  types.ErrorKindRateLimited,    // api concurrent out of limit
+	45047:  types.ErrorKindRateLimited,    // message count out of limit
+	40014:  types.ErrorKindTokenExpired,   // invalid access_token
+	41001:  types.ErrorKindTokenExpired,   // access_token missing
+	42001:  types.ErrorKindTokenExpired,   // access_token expired
+	40001:  types.ErrorKindAuth,           // invalid secret
+	40013:  types.ErrorKindAuth,           // invalid corpid
+	40056:  types.ErrorKindAuth,           // invalid agentid
+	48002:  types.ErrorKindAuth,           // api forbidden
+	60020:  types.ErrorKindAuth,           // ip not in whitelist
+	301002: types.ErrorKindAuth,           // no privilege to access
+	40003:  types.ErrorKindInvalidTarget,  // invalid userid
+	81013:  types.ErrorKindInvalidTarget,  // user, party and tag are all invalid
+	82001:  types.ErrorKindInvalidTarget,  // all touser, toparty and totag are invalid
+	86003:  types.ErrorKindInvalidTarget,  // chat doesn't exist
+	40008:  types.ErrorKindInvalidRequest, // invalid message type
+	44004:  types.ErrorKindInvalidRequest, // empty text content
+	45002:  types.ErrorKindInvalidRequest, // content size out of limit
+}
+
+// newAPIError builds a typed error from a WeChat Work response
+func newAPIError(resp *http.Response, errCode int, errMsg string) *types.APIError {
+	kind, ok := errorKinds[errCode]
+	if !ok && resp.StatusCode >= http.StatusInternalServerError {
+		kind = types.ErrorKindServer
+	}
+
+	return &types.APIError{
+		Platform:   "wechat",
+		HTTPStatus: resp.StatusCode,
+		Code:       errCode,
+		Message:    errMsg,
+		Kind:       kind,
+	}
+}
+
+// apiError builds a typed error and drops the cached token when the API rejected it
+func (c *Client) apiError(resp *http.Response, code int, msg string) *types.APIError {
+	apiErr := newAPIError(resp, code, msg)
+	if apiErr.Kind == types.ErrorKindTokenExpired {
+		c.tokenMu.Lock()
+		c.tokenExpiry = time.Time{}
+		c.tokenMu.Unlock()
+	}
+	return apiErr
+}
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,17 +16,41 @@ import (
 )
 
 const (
-	// WeChat Work API endpoints
-	tokenURL       = "https://qyapi.weixin.qq.com/cgi-bin/gettoken"
-	sendMessageURL = "https://qyapi.weixin.qq.com/cgi-bin/message/send"
+	// WeChat Work API endpoints, relative to the base URL
+	defaultBaseURL  = "https://qyapi.weixin.qq.com"
+	tokenPath       = "/cgi-bin/gettoken"
+	sendMessagePath = "/cgi-bin/message/send"
+	recallPath      = "/cgi-bin/message/recall"
+	appChatCreate   = "/cgi-bin/appchat/create"
+	appChatUpdate   = "/cgi-bin/appchat/update"
+	appChatGet      = "/cgi-bin/appchat/get"
+	appChatSend     = "/cgi-bin/appchat/send"
 )
 
+// Target kinds of WeChat Work besides types.ChatTypePrivate (touser). For
+// compatibility types.ChatTypeGroup targets departments unless Config.AppChatGroups is set.
+const (
+	ChatTypeDepartment types.ChatType = "department" // Department ID (toparty)
+	ChatTypeTag        types.ChatType = "tag"        // Tag ID (totag)
+	ChatTypeAppChat    types.ChatType = "appchat"    // Group chat created by the app, see CreateAppChat (appchat/send)
+)
+
+// AllUsers is the ID of a private target that sends to every member in the app's visible range
+const AllUsers = "@all"
+
 // Config represents WeChat Work configuration
 type Config struct {
-	CorpID     string // Enterprise ID
-	CorpSecret string // Application secret
-	AgentID    int    // Application agent ID
-	BaseURL    string // Optional: custom base URL
+	CorpID      string             // Enterprise ID
+	CorpSecret  string             // Application secret
+	AgentID     int                // Application agent ID
+	BaseURL     string             // Optional: API base URL for a private deployment, proxy or test server (default: https://qyapi.weixin.qq.com)
+	RetryPolicy *types.RetryPolicy // Optional: retry policy for failed sends (default: types.DefaultRetryPolicy())
+	RateLimit   *types.RateLimit   // Optional: client-side rate limit (default: DefaultRateLimit(), &types.RateLimit{} disables it)
+	Concurrency int                // Optional: targets sent to in parallel by SendMessage (default: 1)
+
+	// Optional: send types.ChatTypeGroup targets to app group chats (appchat/send)
+	// instead of departments (toparty)
+	AppChatGroups bool
 }
 
 // Validate validates the config
@@ -43,11 +69,29 @@ func (c *Config) GetPlatform() string {
 	return "wechat"
 }
 
+// DefaultRateLimit returns the quotas applied when Config.RateLimit is nil:
+// 20 messages per second per app and 30 per minute to the same receiver
+func DefaultRateLimit() *types.RateLimit {
+	return &types.RateLimit{Rate: 20, Burst: 20, PerTargetRate: types.PerMinute(30), PerTargetBurst: 10}
+}
+
+// MessageLimit returns the content length limit of a message type, used to split
+// messages when SendOptions.Split is set: 2048 bytes for text, 4096 for markdown
+func MessageLimit(msgType types.MessageType) types.Limit {
+	if msgType == types.MessageTypeMarkdown {
+		return types.Limit{Max: 4096, Unit: types.LengthBytes}
+	}
+	return types.Limit{Max: 2048, Unit: types.LengthBytes}
+}
+
 // Client implements IMParrot interface for WeChat Work
 type Client struct {
 	config      *Config
 	httpClient  *http.Client
 	ownsHTTP    bool // Whether the client owns the http.Client and should close it
+	retry       *types.RetryPolicy
+	limiter     *types.RateLimiter
+	baseURL     string
 	token       string
 	tokenMu     sync.RWMutex
 	tokenExpiry time.Time
@@ -63,10 +107,28 @@ func NewClient(config *Config, httpClient *http.Client) (*Client, error) {
 		ownsHTTP = true
 	}
 
+	retry := config.RetryPolicy
+	if retry == nil {
+		retry = types.DefaultRetryPolicy()
+	}
+
+	rateLimit := config.RateLimit
+	if rateLimit == nil {
+		rateLimit = DefaultRateLimit()
+	}
+
+	baseURL := strings.TrimRight(config.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+
 	client := &Client{
 		config:     config,
 		httpClient: httpClient,
 		ownsHTTP:   ownsHTTP,
+		retry:      retry,
+		limiter:    types.NewRateLimiter("wechat", rateLimit),
+		baseURL:    baseURL,
 	}
 
 	// Get initial access token
@@ -84,8 +146,8 @@ func (c *Client) GetPlatformName() string {
 
 // refreshToken gets a new access token
 func (c *Client) refreshToken(ctx context.Context) error {
-	url := fmt.Sprintf("%s?corpid=%s&corpsecret=%s",
-		tokenURL, c.config.CorpID, c.config.CorpSecret)
+	url := fmt.Sprintf("%s%s?corpid=%s&corpsecret=%s",
+		c.baseURL, tokenPath, c.config.CorpID, c.config.CorpSecret)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -115,7 +177,7 @@ func (c *Client) refreshToken(ctx context.Context) error {
 	}
 
 	if tokenResp.ErrCode != 0 {
-		return fmt.Errorf("failed to get token: %s", tokenResp.ErrMsg)
+		return newAPIError(resp, tokenResp.ErrCode, tokenResp.ErrMsg)
 	}
 
 	c.tokenMu.Lock()
@@ -148,76 +210,69 @@ func (c *Client) getToken(ctx context.Context) (string, error) {
 
 // SendMessage sends a message with options (Strategy pattern implementation)
 func (c *Client) SendMessage(ctx context.Context, msg *types.Message, opts *types.SendOptions) error {
+	_, err := c.SendMessageWithResult(ctx, msg, opts)
+	return err
+}
+
+// SendMessageWithResult sends a message and returns a receipt for every delivered target
+func (c *Client) SendMessageWithResult(ctx context.Context, msg *types.Message, opts *types.SendOptions) ([]types.SendResult, error) {
 	if msg == nil || opts == nil {
-		return fmt.Errorf("message and options cannot be nil")
+		return nil, fmt.Errorf("message and options cannot be nil")
 	}
 
 	if len(opts.Targets) == 0 {
-		return fmt.Errorf("at least one target is required")
-	}
-
-	// Send to multiple targets with retry
-	const maxRetries = 3
-	failedTargets := make([]types.FailedTarget, 0)
-	successCount := 0
-
-	for _, target := range opts.Targets {
-		var lastErr error
-		sent := false
-
-		// Retry up to maxRetries times for each target
-		for retry := 0; retry < maxRetries; retry++ {
-			if err := c.sendToSingleTarget(ctx, msg, target); err != nil {
-				lastErr = err
-				// Wait a bit before retrying (exponential backoff)
-				if retry < maxRetries-1 {
-					time.Sleep(time.Duration(100*(retry+1)) * time.Millisecond)
-				}
-			} else {
-				sent = true
-				successCount++
-				break
-			}
-		}
-
-		// Record failed target after all retries exhausted
-		if !sent {
-			failedTargets = append(failedTargets, types.FailedTarget{
-				Target: target,
-				Error:  lastErr,
-			})
-		}
+		return nil, fmt.Errorf("at least one target is required")
 	}
 
-	// Return error with failed targets information
-	if len(failedTargets) > 0 {
-		return &types.SendError{
-			FailedTargets: failedTargets,
-			SuccessCount:  successCount,
-			TotalCount:    len(opts.Targets),
-		}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = c.config.Concurrency
 	}
 
-	return nil
+	// Fan out to the targets, retrying each (part) according to the retry policy
+	return types.FanOut(ctx, opts.Targets, concurrency, func(ctx context.Context, target types.Target) (*types.SendResult, error) {
+		return types.SendSplit(ctx, msg, opts, MessageLimit(msg.Type), func(ctx context.Context, msg *types.Message) (*types.SendResult, error) {
+			var result *types.SendResult
+			err := c.retry.Do(ctx, func(ctx context.Context) error {
+				var err error
+				result, err = c.sendToSingleTarget(ctx, msg, target)
+				return err
+			})
+			return result, err
+		})
+	})
 }
 
 // sendToSingleTarget sends a message to a single target
-func (c *Client) sendToSingleTarget(ctx context.Context, msg *types.Message, target types.Target) error {
-	token, err := c.getToken(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get access token: %w", err)
+func (c *Client) sendToSingleTarget(ctx context.Context, msg *types.Message, target types.Target) (*types.SendResult, error) {
+	// Wait for the client-wide and per-target quotas before every attempt
+	if err := c.limiter.Wait(ctx, target.Key()); err != nil {
+		return nil, err
 	}
 
 	// Build message content based on type
-	reqBody := map[string]interface{}{
-		"agentid": c.config.AgentID,
-	}
+	reqBody := make(map[string]interface{})
+	path := sendMessagePath
 
 	// Set recipient based on chat type
-	if target.ChatType == types.ChatTypePrivate {
+	chatType := target.ChatType
+	if chatType == types.ChatTypeGroup && c.config.AppChatGroups {
+		chatType = ChatTypeAppChat
+	}
+	switch chatType {
+	case types.ChatTypePrivate:
 		reqBody["touser"] = target.ID
-	} else {
-		reqBody["toparty"] = target.ID // or toparty for department
+	case ChatTypeTag:
+		reqBody["totag"] = target.ID
+	case ChatTypeAppChat:
+		path = appChatSend
+		reqBody["chatid"] = target.ID
+	default:
+		// Departments, and groups for existing callers
+		reqBody["toparty"] = target.ID
+	}
+	if path == sendMessagePath {
+		reqBody["agentid"] = c.config.AgentID
 	}
 
 	// Set message content
@@ -239,27 +294,96 @@ func (c *Client) sendToSingleTarget(ctx context.Context, msg *types.Message, tar
 		}
 	}
 
-	body, err := json.Marshal(reqBody)
+	// appchat/send doesn't return a message ID
+	var apiResp struct {
+		MsgID string `json:"msgid"`
+	}
+	respBody, err := c.callAPI(ctx, http.MethodPost, path, nil, reqBody, &apiResp)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// WeChat Work doesn't report a send time or chat, so the target is the chat
+	return &types.SendResult{
+		Target:    target,
+		MessageID: apiResp.MsgID,
+		ChatID:    target.ID,
+		Timestamp: time.Now(),
+		Raw:       respBody,
+	}, nil
+}
+
+// SendPrivateMessage sends a private message to a user
+func (c *Client) SendPrivateMessage(ctx context.Context, userID string, msg *types.Message) error {
+	return c.SendMessage(ctx, msg, &types.SendOptions{
+		Targets: []types.Target{{ID: userID, ChatType: types.ChatTypePrivate}},
+	})
+}
+
+// SendGroupMessage sends a message to a department, or to an app group chat when
+// Config.AppChatGroups is set
+func (c *Client) SendGroupMessage(ctx context.Context, groupID string, msg *types.Message) error {
+	return c.SendMessage(ctx, msg, &types.SendOptions{
+		Targets: []types.Target{{ID: groupID, ChatType: types.ChatTypeGroup}},
+	})
+}
+
+// UpdateMessage is not supported: WeChat Work can only update template cards
+// through their response codes, not arbitrary application messages
+func (c *Client) UpdateMessage(ctx context.Context, receipt *types.SendResult, msg *types.Message) error {
+	return &types.UnsupportedError{Platform: "wechat", Operation: "UpdateMessage"}
+}
+
+// RecallMessage withdraws a message sent within the last 24 hours (message/recall)
+func (c *Client) RecallMessage(ctx context.Context, receipt *types.SendResult) error {
+	if receipt == nil || receipt.MessageID == "" {
+		return fmt.Errorf("receipt with message ID cannot be nil")
 	}
 
-	url := fmt.Sprintf("%s?access_token=%s", sendMessageURL, token)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	_, err := c.callAPI(ctx, http.MethodPost, recallPath, nil, map[string]string{"msgid": receipt.MessageID}, nil)
+	return err
+}
+
+// callAPI calls a server API with the access token, encoding reqBody (if not nil)
+// as JSON, and decodes the response into out (if not nil). It returns the raw
+// response, or a typed error when errcode isn't 0.
+func (c *Client) callAPI(ctx context.Context, method, path string, query url.Values, reqBody, out interface{}) ([]byte, error) {
+	token, err := c.getToken(ctx)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	var body io.Reader
+	if reqBody != nil {
+		data, err := json.Marshal(reqBody)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("access_token", token)
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path+"?"+query.Encode(), body)
+	if err != nil {
+		return nil, err
+	}
+	if reqBody != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
-	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var apiResp struct {
@@ -268,28 +392,19 @@ func (c *Client) sendToSingleTarget(ctx context.Context, msg *types.Message, tar
 	}
 
 	if err := json.Unmarshal(respBody, &apiResp); err != nil {
-		return err
+		return nil, err
 	}
 
 	if apiResp.ErrCode != 0 {
-		return fmt.Errorf("wechat API error: %s", apiResp.ErrMsg)
+		return nil, c.apiError(resp, apiResp.ErrCode, apiResp.ErrMsg)
 	}
 
-	return nil
-}
-
-// SendPrivateMessage sends a private message to a user
-func (c *Client) SendPrivateMessage(ctx context.Context, userID string, msg *types.Message) error {
-	return c.SendMessage(ctx, msg, &types.SendOptions{
-		Targets: []types.Target{{ID: userID, ChatType: types.ChatTypePrivate}},
-	})
-}
-
-// SendGroupMessage sends a message to a department/group
-func (c *Client) SendGroupMessage(ctx context.Context, groupID string, msg *types.Message) error {
-	return c.SendMessage(ctx, msg, &types.SendOptions{
-		Targets: []types.Target{{ID: groupID, ChatType: types.ChatTypeGroup}},
-	})
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return nil, err
+		}
+	}
+	return respBody, nil
 }
 
 // Close releases all resources held by the client
@@ -0,0 +1,48 @@
+package wpsxz
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+// newAPIError builds a typed error from a WPS Xiezuo response.
+// WPS reports failures through the HTTP status, so classification is by status.
+func newAPIError(resp *http.Response, code int, msg string) *types.APIError {
+	var kind types.ErrorKind
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		kind = types.ErrorKindRateLimited
+	case resp.StatusCode == http.StatusUnauthorized:
+		kind = types.ErrorKindTokenExpired
+	case resp.StatusCode == http.StatusForbidden:
+		kind = types.ErrorKindAuth
+	case resp.StatusCode == http.StatusNotFound:
+		kind = types.ErrorKindInvalidTarget
+	case resp.StatusCode >= http.StatusInternalServerError:
+		kind = types.ErrorKindServer
+	case resp.StatusCode >= http.StatusBadRequest:
+		kind = types.ErrorKindInvalidRequest
+	}
+
+	return &types.APIError{
+		Platform:   "wpsxz",
+		HTTPStatus: resp.StatusCode,
+		Code:       code,
+		Message:    msg,
+		RequestID:  resp.Header.Get("X-Request-Id"),
+		Kind:       kind,
+	}
+}
+
+// apiError builds a typed error and drops the cached token when the API rejected it
+func (c *Client) apiError(resp *http.Response, code int, msg string) *types.APIError {
+	apiErr := newAPIError(resp, code, msg)
+	if apiErr.Kind == types.ErrorKindTokenExpired {
+		c.tokenMu.Lock()
+		c.tokenExpiry = time.Time{}
+		c.tokenMu.Unlock()
+	}
+	return apiErr
+}
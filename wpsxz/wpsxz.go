@@ -0,0 +1,395 @@
+package wpsxz
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JiSuanSiWeiShiXun/parrot/types"
+)
+
+const (
+	// WPS Xiezuo (WPS 协作) open platform endpoints
+	defaultBaseURL      = "https://openapi.wps.cn"
+	tokenPath           = "/oauth2/token"
+	sendMessagePath     = "/v7/messages/create"
+	receiverTypeUser    = "user"
+	receiverTypeChat    = "chat"
+	textContentPlain    = "plain"
+	textContentMarkdown = "markdown"
+)
+
+// Config represents WPS Xiezuo configuration
+type Config struct {
+	AppID       string             // Application client_id
+	AppSecret   string             // Application client_secret
+	BaseURL     string             // Optional: custom base URL (private deployment or test)
+	RetryPolicy *types.RetryPolicy // Optional: retry policy for failed sends (default: types.DefaultRetryPolicy())
+	RateLimit   *types.RateLimit   // Optional: client-side rate limit (default: DefaultRateLimit(), &types.RateLimit{} disables it)
+	Concurrency int                // Optional: targets sent to in parallel by SendMessage (default: 1)
+}
+
+// Validate validates the config
+func (c *Config) Validate() error {
+	if c.AppID == "" {
+		return fmt.Errorf("AppID is required")
+	}
+	if c.AppSecret == "" {
+		return fmt.Errorf("AppSecret is required")
+	}
+	return nil
+}
+
+// GetPlatform returns the platform name
+func (c *Config) GetPlatform() string {
+	return "wpsxz"
+}
+
+// DefaultRateLimit returns the quotas applied when Config.RateLimit is nil:
+// 20 messages per second per app and 5 per second to the same receiver
+func DefaultRateLimit() *types.RateLimit {
+	return &types.RateLimit{Rate: 20, Burst: 20, PerTargetRate: 5, PerTargetBurst: 5}
+}
+
+// Client implements IMParrot interface for WPS Xiezuo
+type Client struct {
+	config      *Config
+	httpClient  *http.Client
+	ownsHTTP    bool // Whether the client owns the http.Client and should close it
+	retry       *types.RetryPolicy
+	limiter     *types.RateLimiter
+	baseURL     string
+	token       string
+	tokenMu     sync.RWMutex
+	tokenExpiry time.Time
+	closed      bool
+	closedMu    sync.RWMutex
+}
+
+// NewClient creates a new WPS Xiezuo client
+func NewClient(config *Config, httpClient *http.Client) (*Client, error) {
+	ownsHTTP := false
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+		ownsHTTP = true
+	}
+
+	retry := config.RetryPolicy
+	if retry == nil {
+		retry = types.DefaultRetryPolicy()
+	}
+
+	rateLimit := config.RateLimit
+	if rateLimit == nil {
+		rateLimit = DefaultRateLimit()
+	}
+
+	baseURL := strings.TrimRight(config.BaseURL, "/")
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+
+	client := &Client{
+		config:     config,
+		httpClient: httpClient,
+		ownsHTTP:   ownsHTTP,
+		retry:      retry,
+		limiter:    types.NewRateLimiter("wpsxz", rateLimit),
+		baseURL:    baseURL,
+	}
+
+	// Get initial access token
+	if err := client.refreshToken(context.Background()); err != nil {
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	return client, nil
+}
+
+// GetPlatformName returns the platform name
+func (c *Client) GetPlatformName() string {
+	return "wpsxz"
+}
+
+// refreshToken gets a new app access token using the client credentials grant
+func (c *Client) refreshToken(ctx context.Context) error {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", c.config.AppID)
+	form.Set("client_secret", c.config.AppSecret)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+tokenPath, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var tokenResp struct {
+		Code        int    `json:"code"`
+		Msg         string `json:"msg"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+
+	if err := json.Unmarshal(respBody, &tokenResp); err != nil {
+		return err
+	}
+
+	if tokenResp.Code != 0 || tokenResp.AccessToken == "" {
+		msg := tokenResp.Msg
+		if msg == "" {
+			msg = "no access token returned"
+		}
+		return newAPIError(resp, tokenResp.Code, msg)
+	}
+
+	c.tokenMu.Lock()
+	c.token = tokenResp.AccessToken
+	c.tokenExpiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn-300) * time.Second) // Refresh 5 min early
+	c.tokenMu.Unlock()
+
+	return nil
+}
+
+// getToken returns a valid access token, refreshing if necessary
+func (c *Client) getToken(ctx context.Context) (string, error) {
+	c.tokenMu.RLock()
+	if time.Now().Before(c.tokenExpiry) {
+		token := c.token
+		c.tokenMu.RUnlock()
+		return token, nil
+	}
+	c.tokenMu.RUnlock()
+
+	if err := c.refreshToken(ctx); err != nil {
+		return "", err
+	}
+
+	c.tokenMu.RLock()
+	token := c.token
+	c.tokenMu.RUnlock()
+	return token, nil
+}
+
+// SendMessage sends a message with options (Strategy pattern implementation)
+func (c *Client) SendMessage(ctx context.Context, msg *types.Message, opts *types.SendOptions) error {
+	_, err := c.SendMessageWithResult(ctx, msg, opts)
+	return err
+}
+
+// SendMessageWithResult sends a message and returns a receipt for every delivered target
+func (c *Client) SendMessageWithResult(ctx context.Context, msg *types.Message, opts *types.SendOptions) ([]types.SendResult, error) {
+	if msg == nil || opts == nil {
+		return nil, fmt.Errorf("message and options cannot be nil")
+	}
+
+	if len(opts.Targets) == 0 {
+		return nil, fmt.Errorf("at least one target is required")
+	}
+
+	// The message length limit isn't documented, so long messages can't be split
+	if opts.Split {
+		return nil, &types.UnsupportedError{Platform: "wpsxz", Operation: "splitting messages"}
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = c.config.Concurrency
+	}
+
+	// Fan out to the targets, retrying each according to the retry policy
+	return types.FanOut(ctx, opts.Targets, concurrency, func(ctx context.Context, target types.Target) (*types.SendResult, error) {
+		var result *types.SendResult
+		err := c.retry.Do(ctx, func(ctx context.Context) error {
+			var err error
+			result, err = c.sendToSingleTarget(ctx, msg, target)
+			return err
+		})
+		return result, err
+	})
+}
+
+// sendToSingleTarget sends a message to a single target
+func (c *Client) sendToSingleTarget(ctx context.Context, msg *types.Message, target types.Target) (*types.SendResult, error) {
+	// Wait for the client-wide and per-target quotas before every attempt
+	if err := c.limiter.Wait(ctx, target.Key()); err != nil {
+		return nil, err
+	}
+
+	token, err := c.getToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	// Determine receiver type based on chat type
+	receiverType := receiverTypeUser
+	if target.ChatType == types.ChatTypeGroup {
+		receiverType = receiverTypeChat
+	}
+
+	reqBody := map[string]interface{}{
+		"receiver": map[string]interface{}{
+			"type":         receiverType,
+			"receiver_ids": []string{target.ID},
+		},
+	}
+
+	// Build message content based on type
+	switch msg.Type {
+	case types.MessageTypeText:
+		reqBody["type"] = "text"
+		reqBody["content"] = map[string]interface{}{
+			"text": map[string]interface{}{
+				"content": msg.Content,
+				"type":    textContentPlain,
+			},
+		}
+	case types.MessageTypeMarkdown:
+		// WPS renders markdown through the text message with a markdown content type
+		reqBody["type"] = "text"
+		reqBody["content"] = map[string]interface{}{
+			"text": map[string]interface{}{
+				"content": msg.Content,
+				"type":    textContentMarkdown,
+			},
+		}
+	case types.MessageTypeCard:
+		// Interactive card - content should be the card JSON
+		var cardData map[string]interface{}
+		if err := json.Unmarshal([]byte(msg.Content), &cardData); err != nil {
+			return nil, types.Permanent(fmt.Errorf("invalid card JSON: %w", err))
+		}
+		reqBody["type"] = "card"
+		reqBody["content"] = map[string]interface{}{
+			"card": cardData,
+		}
+	default:
+		reqBody["type"] = "text"
+		reqBody["content"] = map[string]interface{}{
+			"text": map[string]interface{}{
+				"content": msg.Content,
+				"type":    textContentPlain,
+			},
+		}
+	}
+
+	// Add extra options from msg.Data
+	if msg.Data != nil {
+		for k, v := range msg.Data {
+			reqBody[k] = v
+		}
+	}
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+sendMessagePath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var apiResp struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+		Data struct {
+			MessageID string `json:"message_id"`
+		} `json:"data"`
+	}
+
+	if err := json.Unmarshal(respBody, &apiResp); err != nil {
+		return nil, err
+	}
+
+	if apiResp.Code != 0 {
+		return nil, c.apiError(resp, apiResp.Code, apiResp.Msg)
+	}
+
+	return &types.SendResult{
+		Target:    target,
+		MessageID: apiResp.Data.MessageID,
+		ChatID:    target.ID,
+		Timestamp: time.Now(),
+		Raw:       respBody,
+	}, nil
+}
+
+// SendPrivateMessage sends a private message to a user
+func (c *Client) SendPrivateMessage(ctx context.Context, userID string, msg *types.Message) error {
+	return c.SendMessage(ctx, msg, &types.SendOptions{
+		Targets: []types.Target{{ID: userID, ChatType: types.ChatTypePrivate}},
+	})
+}
+
+// SendGroupMessage sends a message to a group chat
+func (c *Client) SendGroupMessage(ctx context.Context, groupID string, msg *types.Message) error {
+	return c.SendMessage(ctx, msg, &types.SendOptions{
+		Targets: []types.Target{{ID: groupID, ChatType: types.ChatTypeGroup}},
+	})
+}
+
+// UpdateMessage is not supported: WPS Xiezuo application messages can't be edited after sending
+func (c *Client) UpdateMessage(ctx context.Context, receipt *types.SendResult, msg *types.Message) error {
+	return &types.UnsupportedError{Platform: "wpsxz", Operation: "UpdateMessage"}
+}
+
+// RecallMessage is not supported: WPS Xiezuo application messages can't be withdrawn through the API
+func (c *Client) RecallMessage(ctx context.Context, receipt *types.SendResult) error {
+	return &types.UnsupportedError{Platform: "wpsxz", Operation: "RecallMessage"}
+}
+
+// Close releases all resources held by the client
+func (c *Client) Close() error {
+	c.closedMu.Lock()
+	defer c.closedMu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+
+	c.closed = true
+
+	// Close HTTP client connections if we own it
+	if c.ownsHTTP && c.httpClient != nil {
+		c.httpClient.CloseIdleConnections()
+	}
+
+	// Clear token
+	c.tokenMu.Lock()
+	c.token = ""
+	c.tokenMu.Unlock()
+
+	return nil
+}